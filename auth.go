@@ -0,0 +1,155 @@
+package main
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+const AuthorizeUrl = "https://accounts.spotify.com/authorize"
+const TokenUrl = "https://accounts.spotify.com/api/token"
+
+// expiryDelta is how long before the real expiry a token is considered stale, so that
+// a request started right before expiration does not fail half way.
+const expiryDelta = time.Minute
+
+// tokenTimeout limits requests to the token endpoint. Refresh runs under the credential
+// store lock, so it must be clearly shorter than lockTimeout other invocations wait for.
+const tokenTimeout = 10 * time.Second
+
+type tokenResponse struct {
+    AccessToken      string `json:"access_token"`
+    TokenType        string `json:"token_type"`
+    Scope            string `json:"scope"`
+    ExpiresIn        int    `json:"expires_in"`
+    RefreshToken     string `json:"refresh_token"`
+    Error            string `json:"error"`
+    ErrorDescription string `json:"error_description"`
+}
+
+// oauthError is the error accounts service responds with, as described in RFC 6749
+type oauthError struct {
+    Code        string
+    Description string
+}
+
+func (e *oauthError) Error() string {
+    return e.Code + ": " + e.Description
+}
+
+// newCodeVerifier generates PKCE code verifier as described in RFC 7636:
+// 64 random bytes give us 86 chars, well within the allowed 43-128 range.
+func newCodeVerifier() (string, error) {
+    return randomString(64)
+}
+
+// newState generates state parameter, which comes back with the redirect and tells us
+// the redirect is an answer to our own request
+func newState() (string, error) {
+    return randomString(16)
+}
+
+func randomString(size int) (string, error) {
+    b := make([]byte, size)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge derives S256 code challenge from the verifier.
+func codeChallenge(verifier string) string {
+    sum := sha256.Sum256([]byte(verifier))
+    return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizeUrl(config *Config, scopes string, redirectUri string, challenge string, state string) string {
+    query := url.Values{
+        "client_id":             {config.clientId()},
+        "response_type":         {"code"},
+        "redirect_uri":          {redirectUri},
+        "state":                 {state},
+        "code_challenge_method": {"S256"},
+        "code_challenge":        {challenge},
+        "scope":                 {scopes},
+    }
+    return AuthorizeUrl + "?" + query.Encode()
+}
+
+// exchangeCode trades authorization code received on redirect for the token pair.
+func exchangeCode(config *Config, code string, verifier string, redirectUri string) (*Credentials, error) {
+    return requestToken(url.Values{
+        "grant_type":    {"authorization_code"},
+        "code":          {code},
+        "redirect_uri":  {redirectUri},
+        "client_id":     {config.clientId()},
+        "code_verifier": {verifier},
+    })
+}
+
+// refreshToken gets new access token using refresh token. Spotify may or may not
+// rotate refresh token, so the old one is kept if nothing new came back.
+func refreshToken(config *Config, c *Credentials) (*Credentials, error) {
+    if c.RefreshToken == "" {
+        return nil, fmt.Errorf("%w: no refresh token stored", ErrNotLoggedIn)
+    }
+    if config.clientId() == "" {
+        return nil, ErrNoClientId
+    }
+    fresh, err := requestToken(url.Values{
+        "grant_type":    {"refresh_token"},
+        "refresh_token": {c.RefreshToken},
+        "client_id":     {config.clientId()},
+    })
+    var oauthErr *oauthError
+    if errors.As(err, &oauthErr) && oauthErr.Code == "invalid_grant" {
+        return nil, fmt.Errorf("%w: refresh token was revoked", ErrNotLoggedIn)
+    }
+    if err != nil {
+        return nil, err
+    }
+    if fresh.RefreshToken == "" {
+        fresh.RefreshToken = c.RefreshToken
+    }
+    if len(fresh.Scopes) == 0 {
+        fresh.Scopes = c.Scopes
+    }
+    fresh.AccountId = c.AccountId
+    return fresh, nil
+}
+
+func requestToken(form url.Values) (*Credentials, error) {
+    client := &http.Client{Timeout: tokenTimeout}
+    response, err := client.Post(TokenUrl, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+    if err != nil {
+        return nil, err
+    }
+    defer response.Body.Close()
+
+    tempBody, _ := ioutil.ReadAll(response.Body)
+    var resBody tokenResponse
+    if jsonErr := json.Unmarshal(tempBody, &resBody); jsonErr != nil {
+        return nil, errors.New("cannot decode token response")
+    }
+    if resBody.Error != "" {
+        return nil, &oauthError{Code: resBody.Error, Description: resBody.ErrorDescription}
+    }
+    if response.StatusCode != http.StatusOK || resBody.AccessToken == "" {
+        return nil, errors.New("token endpoint returned " + response.Status)
+    }
+
+    return &Credentials{
+        AccessToken:  resBody.AccessToken,
+        RefreshToken: resBody.RefreshToken,
+        Scopes:       strings.Fields(resBody.Scope),
+        Expiry:       time.Now().Add(time.Duration(resBody.ExpiresIn) * time.Second),
+    }, nil
+}
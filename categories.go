@@ -0,0 +1,85 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+
+    "spotify/spotify"
+)
+
+func categoriesCommand() *Command {
+    var limit, offset int
+    return &Command{
+        Name:    "categories",
+        Aliases: []string{"browse"},
+        Description: "List browse categories, or playlists of a category\n" +
+            "Categories are shown with their ids, give one of them to see its playlists.",
+        Args: []Arg{
+            {Name: "category", Description: "id of the category to list playlists of", Optional: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.IntVar(&limit, "limit", 50, "number of items to list, 1-50")
+            fs.IntVar(&offset, "offset", 0, "number of items to skip")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if limit < 1 || limit > 50 {
+                return usageErrorf(ctx.Command, "limit must be within 1-50")
+            }
+            if offset < 0 {
+                return usageErrorf(ctx.Command, "offset cannot be negative")
+            }
+            if len(args) > 0 {
+                return listCategoryPlaylists(ctx, args[0], limit, offset)
+            }
+
+            page, err := ctx.Client.Categories(ctx, limit, offset)
+            if err != nil {
+                return err
+            }
+            categories := page.Items
+            if categories == nil {
+                categories = []spotify.Category{}
+            }
+            return ctx.Render(categories, func(w io.Writer) {
+                if len(categories) == 0 {
+                    _, _ = fmt.Fprintln(w, "No categories available")
+                    return
+                }
+                for _, c := range categories {
+                    _, _ = fmt.Fprintf(w, "%-24s %s\n", c.Id, c.Name)
+                }
+            })
+        },
+    }
+}
+
+func listCategoryPlaylists(ctx *Context, category string, limit int, offset int) error {
+    page, err := ctx.Client.CategoryPlaylists(ctx, category, limit, offset)
+    if err != nil {
+        if errors.Is(err, spotify.ErrNotFound) {
+            return errors.New("no category \"" + category + "\", see `spotify categories`")
+        }
+        return err
+    }
+    playlists := page.Items
+    if playlists == nil {
+        playlists = []spotify.Playlist{}
+    }
+    return ctx.Render(playlists, func(w io.Writer) {
+        if len(playlists) == 0 {
+            _, _ = fmt.Fprintln(w, "No playlists in category "+category)
+            return
+        }
+        lines := make([]string, 0, len(playlists))
+        for _, p := range playlists {
+            line := p.Name
+            if p.Description != "" {
+                line += " — " + p.Description
+            }
+            lines = append(lines, line)
+        }
+        printNumbered(w, lines)
+    })
+}
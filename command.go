@@ -0,0 +1,465 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strings"
+    "text/template"
+
+    "spotify/spotify"
+)
+
+// Command describes a CLI command: how it is called, what it takes and what it does.
+// Commands may have subcommands, in which case Run is optional and called when none of
+// the subcommands matched.
+type Command struct {
+    Name    string
+    Aliases []string
+    // Usage is the arguments part of usage line, generated from Args when empty
+    Usage string
+    // Description is shown in help, its first line is also shown in command lists
+    Description string
+    // Flags defines command flags, usually into variables captured by Run
+    Flags func(fs *flag.FlagSet)
+    // GlobalFlags defines flags accepted by the command and all of its subcommands, also
+    // before the subcommand name
+    GlobalFlags func(fs *flag.FlagSet)
+    // Before prepares context for the command and all of its subcommands, it runs after
+    // flags were parsed, parents first
+    Before      func(ctx *Context) error
+    Args        []Arg
+    Run         func(ctx *Context, args []string) error
+    Subcommands []*Command
+    // Hidden commands work but are not listed in help
+    Hidden bool
+    // AnyProfile commands and their subcommands run even when the selected profile does
+    // not exist, e.g. to list profiles or add the missing one
+    AnyProfile bool
+
+    parent *Command
+}
+
+// Arg is a positional argument, only the last one can be variadic.
+type Arg struct {
+    Name        string
+    Description string
+    Optional    bool
+    Variadic    bool
+}
+
+// Context is what every command gets to do its job.
+type Context struct {
+    context.Context
+    Client  *spotify.Client
+    Store   *CredentialStore
+    Config  *Config
+    Stdin   io.Reader
+    Stdout  io.Writer
+    Stderr  io.Writer
+    // Output is the format of results, see Render
+    Output  string
+    // Format is the template results are executed with instead, if given
+    Format  *template.Template
+    Command *Command
+}
+
+// UsageError is returned when command is called the wrong way, it is reported together
+// with usage of the command.
+type UsageError struct {
+    Command *Command
+    Message string
+}
+
+func (e *UsageError) Error() string {
+    return e.Message
+}
+
+func usageErrorf(cmd *Command, format string, a ...interface{}) error {
+    return &UsageError{Command: cmd, Message: fmt.Sprintf(format, a...)}
+}
+
+// errHelp is returned when help was asked for and already printed
+var errHelp = errors.New("help requested")
+
+// FullName is the command name prefixed with names of its parents, e.g. "spotify device list".
+func (c *Command) FullName() string {
+    if c.parent == nil {
+        return c.Name
+    }
+    return c.parent.FullName() + " " + c.Name
+}
+
+func (c *Command) matches(name string) bool {
+    if c.Name == name {
+        return true
+    }
+    for _, alias := range c.Aliases {
+        if alias == name {
+            return true
+        }
+    }
+    return false
+}
+
+func (c *Command) find(name string) *Command {
+    for _, sub := range c.Subcommands {
+        if sub.matches(name) {
+            return sub
+        }
+    }
+    return nil
+}
+
+func (c *Command) summary() string {
+    if i := strings.Index(c.Description, "\n"); i >= 0 {
+        return c.Description[:i]
+    }
+    return c.Description
+}
+
+func (c *Command) usage() string {
+    if c.Usage != "" {
+        return c.Usage
+    }
+    var parts []string
+    if len(c.Subcommands) > 0 {
+        if c.Run == nil {
+            parts = append(parts, "<command>")
+        } else {
+            parts = append(parts, "[command]")
+        }
+    }
+    for _, a := range c.Args {
+        s := "<" + a.Name + ">"
+        if a.Variadic {
+            s += "..."
+        }
+        if a.Optional {
+            s = "[" + s + "]"
+        }
+        parts = append(parts, s)
+    }
+    return strings.Join(parts, " ")
+}
+
+// flagSet has flags of the command together with global flags of it and its parents,
+// flags of the command shadow global ones of the same name
+func (c *Command) flagSet() *flag.FlagSet {
+    fs := c.ownFlagSet()
+    c.inheritedFlags(fs).VisitAll(func(f *flag.Flag) {
+        fs.Var(f.Value, f.Name, f.Usage)
+    })
+    return fs
+}
+
+func (c *Command) ownFlagSet() *flag.FlagSet {
+    fs := flag.NewFlagSet(c.FullName(), flag.ContinueOnError)
+    fs.SetOutput(ioutil.Discard)
+    if c.Flags != nil {
+        c.Flags(fs)
+    }
+    return fs
+}
+
+// inheritedFlags are global flags not shadowed by flags in own
+func (c *Command) inheritedFlags(own *flag.FlagSet) *flag.FlagSet {
+    fs := flag.NewFlagSet(c.FullName(), flag.ContinueOnError)
+    fs.SetOutput(ioutil.Discard)
+    c.globalFlagSet().VisitAll(func(f *flag.Flag) {
+        if own.Lookup(f.Name) == nil {
+            fs.Var(f.Value, f.Name, f.Usage)
+        }
+    })
+    return fs
+}
+
+func (c *Command) globalFlagSet() *flag.FlagSet {
+    fs := flag.NewFlagSet(c.FullName(), flag.ContinueOnError)
+    fs.SetOutput(ioutil.Discard)
+    for cmd := c; cmd != nil; cmd = cmd.parent {
+        if cmd.GlobalFlags != nil {
+            cmd.GlobalFlags(fs)
+        }
+    }
+    return fs
+}
+
+// anyProfile tells whether the command or any of its parents is AnyProfile
+func (c *Command) anyProfile() bool {
+    for ; c != nil; c = c.parent {
+        if c.AnyProfile {
+            return true
+        }
+    }
+    return false
+}
+
+// before runs Before of the command and its parents, parents first
+func (c *Command) before(ctx *Context) error {
+    if c.parent != nil {
+        if err := c.parent.before(ctx); err != nil {
+            return err
+        }
+    }
+    if c.Before == nil {
+        return nil
+    }
+    return c.Before(ctx)
+}
+
+// link sets parents of the whole command tree, so that full names can be built
+func (c *Command) link() *Command {
+    for _, sub := range c.Subcommands {
+        sub.parent = c
+        sub.link()
+    }
+    return c
+}
+
+// resolve walks down the command tree as long as args name subcommands and returns
+// the deepest matched command with the rest of args. Global flags may come before
+// subcommand names, they are kept in the rest.
+func (c *Command) resolve(args []string) (*Command, []string) {
+    cmd := c
+    var flags []string
+    for len(args) > 0 {
+        if !isPositional(args[0]) && args[0] != "--" {
+            flags = append(flags, args[0])
+            args = args[1:]
+            if len(args) > 0 && needsValue(cmd.globalFlagSet(), flags[len(flags)-1]) {
+                flags = append(flags, args[0])
+                args = args[1:]
+            }
+            continue
+        }
+        sub := cmd.find(args[0])
+        if sub == nil {
+            break
+        }
+        cmd, args = sub, args[1:]
+    }
+    return cmd, append(flags, args...)
+}
+
+// execute resolves the command, parses its flags and args and runs it.
+func (c *Command) execute(ctx *Context, args []string) error {
+    cmd, args := c.resolve(args)
+    ctx.Command = cmd
+
+    fs := cmd.flagSet()
+    positional, err := parseInterspersed(fs, args)
+    if err == flag.ErrHelp {
+        cmd.printHelp(ctx.Stdout)
+        return errHelp
+    }
+    if err != nil {
+        return &UsageError{Command: cmd, Message: err.Error()}
+    }
+
+    if cmd.Run == nil {
+        if len(positional) > 0 {
+            return usageErrorf(cmd, "unknown command %q", positional[0])
+        }
+        return usageErrorf(cmd, "missing command")
+    }
+    if err := cmd.checkArgs(positional); err != nil {
+        return err
+    }
+    if err := cmd.before(ctx); err != nil {
+        return err
+    }
+    return cmd.Run(ctx, positional)
+}
+
+func (c *Command) checkArgs(args []string) error {
+    required, variadic := 0, false
+    for _, a := range c.Args {
+        if !a.Optional {
+            required++
+        }
+        variadic = variadic || a.Variadic
+    }
+    if len(args) < required {
+        return usageErrorf(c, "missing %s", "<"+c.Args[len(args)].Name+">")
+    }
+    if !variadic && len(args) > len(c.Args) {
+        if len(c.Subcommands) > 0 && len(c.Args) == 0 {
+            return usageErrorf(c, "unknown command %q", args[len(c.Args)])
+        }
+        return usageErrorf(c, "unexpected argument %q", args[len(c.Args)])
+    }
+    return nil
+}
+
+// parseInterspersed lets flags go after positional arguments, which flag package does
+// not allow on its own. Arguments looking like negative numbers ("-5", "-10s") are
+// positional, so relative values can be passed without "--".
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+    var positional []string
+    for {
+        var flags []string
+        for len(args) > 0 && !isPositional(args[0]) {
+            if args[0] == "--" {
+                return append(positional, args[1:]...), fs.Parse(flags)
+            }
+            flags = append(flags, args[0])
+            args = args[1:]
+            // value of non-boolean flag given as a separate argument
+            if len(args) > 0 && needsValue(fs, flags[len(flags)-1]) {
+                flags = append(flags, args[0])
+                args = args[1:]
+            }
+        }
+        if err := fs.Parse(flags); err != nil {
+            return nil, err
+        }
+        if len(args) == 0 {
+            return positional, nil
+        }
+        positional = append(positional, args[0])
+        args = args[1:]
+    }
+}
+
+func isPositional(arg string) bool {
+    if !strings.HasPrefix(arg, "-") || arg == "-" {
+        return true
+    }
+    return len(arg) > 1 && (arg[1] >= '0' && arg[1] <= '9' || arg[1] == '.')
+}
+
+func needsValue(fs *flag.FlagSet, arg string) bool {
+    name := strings.TrimLeft(arg, "-")
+    if strings.Contains(name, "=") {
+        return false
+    }
+    f := fs.Lookup(name)
+    if f == nil {
+        return false
+    }
+    if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+        return false
+    }
+    return true
+}
+
+func (c *Command) printHelp(w io.Writer) {
+    fs := c.ownFlagSet()
+    global := c.inheritedFlags(fs)
+    synopsis := c.FullName()
+    if hasFlags(fs) || hasFlags(global) {
+        synopsis += " [flags]"
+    }
+    _, _ = fmt.Fprintf(w, "Usage: %s\n", strings.TrimSpace(synopsis+" "+c.usage()))
+    if len(c.Aliases) > 0 {
+        _, _ = fmt.Fprintf(w, "Aliases: %s\n", strings.Join(c.Aliases, ", "))
+    }
+    if c.Description != "" {
+        _, _ = fmt.Fprintf(w, "\n%s\n", c.Description)
+    }
+
+    if len(c.Args) > 0 {
+        _, _ = fmt.Fprintf(w, "\nArguments:\n")
+        var rows [][2]string
+        for _, a := range c.Args {
+            rows = append(rows, [2]string{a.Name, a.Description})
+        }
+        printColumns(w, rows)
+    }
+
+    var subs []*Command
+    for _, sub := range c.Subcommands {
+        if !sub.Hidden {
+            subs = append(subs, sub)
+        }
+    }
+    if len(subs) > 0 {
+        _, _ = fmt.Fprintf(w, "\nCommands:\n")
+        var rows [][2]string
+        for _, sub := range subs {
+            name := sub.Name
+            if len(sub.Aliases) > 0 {
+                name += " (" + strings.Join(sub.Aliases, ", ") + ")"
+            }
+            rows = append(rows, [2]string{name, sub.summary()})
+        }
+        printColumns(w, rows)
+    }
+
+    if hasFlags(fs) {
+        _, _ = fmt.Fprintf(w, "\nFlags:\n")
+        printFlags(w, fs)
+    }
+    if hasFlags(global) {
+        _, _ = fmt.Fprintf(w, "\nGlobal flags:\n")
+        printFlags(w, global)
+    }
+
+    if len(subs) > 0 {
+        _, _ = fmt.Fprintf(w, "\nRun '%s <command> --help' for more about a command.\n", c.FullName())
+    }
+}
+
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+    var rows [][2]string
+    fs.VisitAll(func(f *flag.Flag) {
+        name, usage := flag.UnquoteUsage(f)
+        s := "--" + f.Name
+        if name != "" {
+            s += " " + name
+        }
+        if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+            usage += " (default " + f.DefValue + ")"
+        }
+        rows = append(rows, [2]string{s, usage})
+    })
+    printColumns(w, rows)
+}
+
+// printColumns prints indented two column list, aligning the second column
+func printColumns(w io.Writer, rows [][2]string) {
+    width := 20
+    for _, row := range rows {
+        if len(row[0]) > width {
+            width = len(row[0])
+        }
+    }
+    for _, row := range rows {
+        _, _ = fmt.Fprintf(w, "  %-*s  %s\n", width, row[0], row[1])
+    }
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+    found := false
+    fs.VisitAll(func(*flag.Flag) {
+        found = true
+    })
+    return found
+}
+
+func helpCommand() *Command {
+    return &Command{
+        Name:        "help",
+        Description: "Show help for a command",
+        AnyProfile:  true,
+        Args: []Arg{
+            {Name: "command", Description: "command to show help for", Optional: true, Variadic: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            root := ctx.Command
+            for root.parent != nil {
+                root = root.parent
+            }
+            cmd, rest := root.resolve(args)
+            if len(rest) > 0 {
+                return usageErrorf(root, "unknown command %q", rest[0])
+            }
+            cmd.printHelp(ctx.Stdout)
+            return nil
+        },
+    }
+}
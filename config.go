@@ -0,0 +1,364 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+const ConfigFileName = "config.json"
+
+const DefaultPort = "7911"
+const DefaultScopes = "ugc-image-upload user-read-playback-state user-modify-playback-state user-read-currently-playing streaming app-remote-control user-library-read user-library-modify playlist-read-private playlist-read-collaborative playlist-modify-private playlist-modify-public"
+
+// ClientToken is the client id used when none is configured, release builds can set it
+// with -ldflags "-X main.ClientToken=..."
+var ClientToken = "" // can be retrieved from https://developer.spotify.com/dashboard/applications
+
+// Settings are what user can configure, both globally and per profile. Empty values
+// mean defaults, see knownSettings for how the effective value is found.
+type Settings struct {
+    ClientId string `json:"client_id,omitempty"`
+    Port     string `json:"port,omitempty"`
+    Scopes   string `json:"scopes,omitempty"`
+    ApiUrl   string `json:"api_url,omitempty"`
+    // PreferredDevices are names or ids of devices to use, in this order, when no
+    // device is active. Nil is not set and inherited, unlike an empty list.
+    PreferredDevices *[]string `json:"preferred_devices,omitempty"`
+}
+
+// Config is the config file, stored as JSON in the user config directory. Top level
+// settings belong to the default profile and are inherited by other profiles.
+type Config struct {
+    Settings
+    // Profile is used when no profile is given by flag or environment
+    Profile  string               `json:"profile,omitempty"`
+    Profiles map[string]*Settings `json:"profiles,omitempty"`
+
+    // active is the profile in use, see selectProfile
+    active string
+}
+
+// setting is a single value of the config file that can be overridden by environment
+// variable; environment wins over the active profile, which wins over top level
+// settings, which win over the default
+type setting struct {
+    Name        string
+    Env         string
+    Description string
+    Default     func() string
+    Field       func(s *Settings) *string
+    // Normalize checks value given to `config set` and returns what should be stored
+    Normalize func(v string) (string, error)
+}
+
+var knownSettings = []setting{
+    {
+        Name:        "client_id",
+        Env:         "SPOTIFY_CLI_CLIENT_ID",
+        Description: "client id of your application from Spotify developer dashboard",
+        Default:     func() string { return ClientToken },
+        Field:       func(s *Settings) *string { return &s.ClientId },
+        Normalize: func(v string) (string, error) {
+            return strings.TrimSpace(v), nil
+        },
+    },
+    {
+        Name:        "port",
+        Env:         "SPOTIFY_CLI_PORT",
+        Description: "local port of login callback, must match redirect URI of the application",
+        Default:     func() string { return DefaultPort },
+        Field:       func(s *Settings) *string { return &s.Port },
+        Normalize: func(v string) (string, error) {
+            port, err := strconv.Atoi(v)
+            if err != nil || port < 1 || port > 65535 {
+                return "", errors.New("port must be a number from 1 to 65535")
+            }
+            return strconv.Itoa(port), nil
+        },
+    },
+    {
+        Name:        "scopes",
+        Env:         "SPOTIFY_CLI_SCOPES",
+        Description: "space separated scopes requested on login",
+        Default:     func() string { return DefaultScopes },
+        Field:       func(s *Settings) *string { return &s.Scopes },
+        Normalize: func(v string) (string, error) {
+            return strings.Join(strings.Fields(v), " "), nil
+        },
+    },
+    {
+        Name:        "api_url",
+        Env:         "SPOTIFY_CLI_API_URL",
+        Description: "base URL of Spotify Web API",
+        Default:     func() string { return spotify.DefaultBaseUrl },
+        Field:       func(s *Settings) *string { return &s.ApiUrl },
+        Normalize: func(v string) (string, error) {
+            u, err := url.Parse(v)
+            if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                return "", errors.New("api_url must be an absolute http or https URL")
+            }
+            return strings.TrimSuffix(v, "/"), nil
+        },
+    },
+}
+
+func configCommand() *Command {
+    var descriptions []string
+    for _, s := range knownSettings {
+        descriptions = append(descriptions, "  "+s.Name+" ("+s.Env+") - "+s.Description)
+    }
+    settingArg := Arg{Name: "name", Description: "one of the settings listed in `spotify config --help`"}
+    return &Command{
+        Name: "config",
+        Description: "Show or change configuration\n" +
+            "Settings are stored in the config file for the profile in use, profiles inherit\n" +
+            "settings they do not set from the default one. Environment variables override them:\n" +
+            strings.Join(descriptions, "\n"),
+        Run: listConfig,
+        Subcommands: []*Command{
+            {
+                Name:        "list",
+                Aliases:     []string{"ls"},
+                Description: "List effective settings and where they come from",
+                Run:         listConfig,
+            },
+            {
+                Name:        "get",
+                Description: "Print effective value of a setting",
+                Args:        []Arg{settingArg},
+                Run: func(ctx *Context, args []string) error {
+                    s, err := findSetting(args[0])
+                    if err != nil {
+                        return err
+                    }
+                    result := ctx.Config.result(s)
+                    return ctx.Render(result, func(w io.Writer) {
+                        _, _ = fmt.Fprintln(w, result.Value)
+                    })
+                },
+            },
+            {
+                Name:        "set",
+                Description: "Store a setting in the config file",
+                Args:        []Arg{settingArg, {Name: "value", Description: "new value"}},
+                Run: func(ctx *Context, args []string) error {
+                    s, err := findSetting(args[0])
+                    if err != nil {
+                        return err
+                    }
+                    v, err := s.Normalize(args[1])
+                    if err != nil {
+                        return usageErrorf(ctx.Command, "%s", err.Error())
+                    }
+                    *s.Field(ctx.Config.settings()) = v
+                    return saveSetting(ctx, s)
+                },
+            },
+            {
+                Name:        "unset",
+                Description: "Remove a setting from the config file, so the inherited or default value is used",
+                Args:        []Arg{settingArg},
+                Run: func(ctx *Context, args []string) error {
+                    s, err := findSetting(args[0])
+                    if err != nil {
+                        return err
+                    }
+                    *s.Field(ctx.Config.settings()) = ""
+                    return saveSetting(ctx, s)
+                },
+            },
+            {
+                Name:        "path",
+                Description: "Print location of the config file",
+                Run: func(ctx *Context, args []string) error {
+                    path, err := configPath()
+                    if err != nil {
+                        return err
+                    }
+                    return ctx.Render(pathResult{Path: path}, func(w io.Writer) {
+                        _, _ = fmt.Fprintln(w, path)
+                    })
+                },
+            },
+        },
+    }
+}
+
+type settingResult struct {
+    Name   string `json:"name"`
+    Value  string `json:"value"`
+    Source string `json:"source"`
+    Env    string `json:"env"`
+}
+
+type pathResult struct {
+    Path string `json:"path"`
+}
+
+func (c *Config) result(s *setting) settingResult {
+    v, source := c.get(s)
+    return settingResult{Name: s.Name, Value: v, Source: source, Env: s.Env}
+}
+
+func listConfig(ctx *Context, args []string) error {
+    results := make([]settingResult, 0, len(knownSettings))
+    for i := range knownSettings {
+        results = append(results, ctx.Config.result(&knownSettings[i]))
+    }
+    return ctx.Render(results, func(w io.Writer) {
+        for _, r := range results {
+            v, source := r.Value, r.Source
+            if source == "env" {
+                source = "from " + r.Env
+            }
+            if v == "" {
+                v = "(not set)"
+            }
+            _, _ = fmt.Fprintf(w, "%-10s %s [%s]\n", r.Name, v, source)
+        }
+    })
+}
+
+// saveSetting writes config file and tells what the setting is now, warning if the
+// stored value is shadowed by environment variable
+func saveSetting(ctx *Context, s *setting) error {
+    if err := saveConfig(ctx.Config); err != nil {
+        return err
+    }
+    result := ctx.Config.result(s)
+    if result.Source == "env" {
+        _, _ = fmt.Fprintln(ctx.Stderr, "Saved, but "+s.Env+" is set and overrides it")
+    }
+    return ctx.Render(result, func(w io.Writer) {
+        _, _ = fmt.Fprintln(w, s.Name+" = "+result.Value)
+    })
+}
+
+func findSetting(name string) (*setting, error) {
+    for i := range knownSettings {
+        if knownSettings[i].Name == name {
+            return &knownSettings[i], nil
+        }
+    }
+    names := make([]string, 0, len(knownSettings))
+    for _, s := range knownSettings {
+        names = append(names, s.Name)
+    }
+    return nil, errors.New("unknown setting \"" + name + "\", expected one of " + strings.Join(names, ", "))
+}
+
+// get returns effective value of the setting and where it comes from: "env",
+// "profile", "file" or "default"
+func (c *Config) get(s *setting) (value string, source string) {
+    if v := os.Getenv(s.Env); v != "" {
+        return v, "env"
+    }
+    if p := c.settings(); p != &c.Settings {
+        if v := *s.Field(p); v != "" {
+            return v, "profile"
+        }
+    }
+    if v := *s.Field(&c.Settings); v != "" {
+        return v, "file"
+    }
+    return s.Default(), "default"
+}
+
+// settings are settings of the active profile, which is where changes go
+func (c *Config) settings() *Settings {
+    if p, ok := c.Profiles[c.active]; ok && c.active != DefaultProfile {
+        return p
+    }
+    return &c.Settings
+}
+
+func (c *Config) preferredDevices() []string {
+    if p := c.settings().PreferredDevices; p != nil {
+        return *p
+    }
+    if c.Settings.PreferredDevices != nil {
+        return *c.Settings.PreferredDevices
+    }
+    return nil
+}
+
+// setPreferredDevices changes devices of the active profile. Profiles keep an empty list,
+// so that clearing them does not bring back the inherited devices.
+func (c *Config) setPreferredDevices(devices []string) {
+    s := c.settings()
+    if len(devices) == 0 && s == &c.Settings {
+        s.PreferredDevices = nil
+        return
+    }
+    if devices == nil {
+        devices = []string{}
+    }
+    s.PreferredDevices = &devices
+}
+
+func (c *Config) value(name string) string {
+    s, err := findSetting(name)
+    if err != nil {
+        panic(err)
+    }
+    v, _ := c.get(s)
+    return v
+}
+
+func (c *Config) clientId() string { return c.value("client_id") }
+func (c *Config) port() string     { return c.value("port") }
+func (c *Config) scopes() string   { return c.value("scopes") }
+func (c *Config) apiUrl() string   { return c.value("api_url") }
+
+func configPath() (string, error) {
+    dir, err := configDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, ConfigFileName), nil
+}
+
+// loadConfig reads config file, missing file is the same as empty config
+func loadConfig() (*Config, error) {
+    var c Config
+    path, err := configPath()
+    if err != nil {
+        return nil, err
+    }
+    content, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return &c, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if jsonErr := json.Unmarshal(content, &c); jsonErr != nil {
+        return nil, errors.New("malformed config file " + path + ": " + jsonErr.Error())
+    }
+    return &c, nil
+}
+
+func saveConfig(c *Config) error {
+    path, err := configPath()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return err
+    }
+    content, err := json.MarshalIndent(c, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(path, append(content, '\n'))
+}
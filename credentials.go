@@ -0,0 +1,190 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+    "time"
+)
+
+const CredentialsFileName = "credentials.json"
+
+// lockTimeout is how long we wait for another invocation to release the store,
+// lockStaleAfter is the age after which a lock is considered abandoned by a crashed process.
+// The lock is held for a token refresh at most, so tokenTimeout < lockTimeout < lockStaleAfter.
+const lockTimeout = 20 * time.Second
+const lockStaleAfter = 60 * time.Second
+
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// Credentials is what we persist after login: both tokens, granted scopes, the moment
+// access token dies and the account they belong to.
+type Credentials struct {
+    AccessToken  string    `json:"access_token"`
+    RefreshToken string    `json:"refresh_token"`
+    Scopes       []string  `json:"scopes"`
+    Expiry       time.Time `json:"expiry"`
+    AccountId    string    `json:"account_id"`
+}
+
+// Expired reports whether access token needs to be refreshed before use.
+func (c *Credentials) Expired() bool {
+    return time.Now().Add(expiryDelta).After(c.Expiry)
+}
+
+// CredentialStore keeps credentials as a JSON document readable only by the owner.
+// Every read-modify-write goes under a lock file, and writes are done by renaming
+// a fully written temp file, so concurrent invocations never see half a document.
+type CredentialStore struct {
+    Path string
+}
+
+// profileCredentialStore is where credentials of the profile live, those of the default
+// profile are right in the state directory
+func profileCredentialStore(profile string) (*CredentialStore, error) {
+    dir, err := profileStateDir(profile)
+    if err != nil {
+        return nil, err
+    }
+    return &CredentialStore{Path: filepath.Join(dir, CredentialsFileName)}, nil
+}
+
+// Load returns stored credentials or ErrNotLoggedIn if there are none.
+func (s *CredentialStore) Load() (*Credentials, error) {
+    content, err := ioutil.ReadFile(s.Path)
+    if os.IsNotExist(err) {
+        return nil, ErrNotLoggedIn
+    }
+    if err != nil {
+        return nil, err
+    }
+    var c Credentials
+    if jsonErr := json.Unmarshal(content, &c); jsonErr != nil {
+        return nil, errors.New("malformed credentials file " + s.Path)
+    }
+    if c.AccessToken == "" {
+        return nil, ErrNotLoggedIn
+    }
+    return &c, nil
+}
+
+// Save replaces stored credentials.
+func (s *CredentialStore) Save(c *Credentials) error {
+    return s.Update(func(_ *Credentials) (*Credentials, error) {
+        return c, nil
+    })
+}
+
+// Delete removes stored credentials, returns ErrNotLoggedIn if there were none.
+func (s *CredentialStore) Delete() error {
+    if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+        return ErrNotLoggedIn
+    }
+    unlock, err := lockFile(s.Path + ".lock")
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    err = os.Remove(s.Path)
+    if os.IsNotExist(err) {
+        return ErrNotLoggedIn
+    }
+    return err
+}
+
+// Update runs fn with the currently stored credentials (nil if there are none) while
+// holding the lock and stores whatever fn returns. Returning nil credentials leaves
+// the store untouched.
+func (s *CredentialStore) Update(fn func(c *Credentials) (*Credentials, error)) error {
+    if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+        return err
+    }
+    unlock, err := lockFile(s.Path + ".lock")
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    current, err := s.Load()
+    if err != nil && err != ErrNotLoggedIn {
+        return err
+    }
+    updated, err := fn(current)
+    if err != nil || updated == nil {
+        return err
+    }
+    content, err := json.MarshalIndent(updated, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(s.Path, content)
+}
+
+// writeFileAtomic writes content next to the target and renames it over, the temp file
+// is created with 0600 permissions already.
+func writeFileAtomic(path string, content []byte) error {
+    tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.Write(content); err != nil {
+        _ = tmp.Close()
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        _ = tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmp.Name(), 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// lockFile takes an exclusive lock by creating the lock file, which works the same on
+// every platform. Locks left behind by crashed processes are removed once stale.
+func lockFile(path string) (unlock func(), err error) {
+    deadline := time.Now().Add(lockTimeout)
+    for {
+        f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+        if err == nil {
+            _ = f.Close()
+            return func() { _ = os.Remove(path) }, nil
+        }
+        if !os.IsExist(err) {
+            return nil, err
+        }
+        if fi, statErr := os.Stat(path); statErr == nil && time.Since(fi.ModTime()) > lockStaleAfter {
+            breakStaleLock(path)
+            continue
+        }
+        if time.Now().After(deadline) {
+            return nil, errors.New(path + " is held by another invocation for over " +
+                strconv.Itoa(int(lockTimeout.Seconds())) + " seconds, try again")
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+}
+
+// breakStaleLock moves the lock away under a name of our own, so that two waiters cannot
+// both remove it and one of them delete the fresh lock of the other. If the lock turns out
+// to be fresh by then, somebody else broke the stale one first and it is put back.
+func breakStaleLock(path string) {
+    moved := path + ".stale-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+    if err := os.Rename(path, moved); err != nil {
+        return
+    }
+    if fi, err := os.Stat(moved); err == nil && time.Since(fi.ModTime()) <= lockStaleAfter {
+        _ = os.Link(moved, path)
+    }
+    _ = os.Remove(moved)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+func deviceCommand() *Command {
+    var noPlay bool
+    return &Command{
+        Name: "device",
+        Description: "Move playback to another device\n" +
+            "Device is picked by its number in `device list`, id or name; name may be any part of it\n" +
+            "as long as it matches one device only, case does not matter. Without argument you are\n" +
+            "asked to pick one.",
+        Args: []Arg{
+            {Name: "name|id|number", Description: "device to move playback to", Optional: true, Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.BoolVar(&noPlay, "no-play", false, "keep current playback state instead of starting playback")
+        },
+        Run: func(ctx *Context, args []string) error {
+            devices, err := ctx.Client.Devices(ctx)
+            if err != nil {
+                return err
+            }
+            if len(devices) == 0 {
+                return ErrNoDevices
+            }
+
+            var device *spotify.Device
+            switch {
+            case len(args) > 0:
+                if device, err = matchDevice(devices, strings.Join(args, " ")); err != nil {
+                    return err
+                }
+            case len(devices) == 1:
+                device = &devices[0]
+            case isInteractive(ctx):
+                _, _ = fmt.Fprintln(ctx.Stderr, "Available devices:")
+                printNumbered(ctx.Stderr, describeDevices(devices))
+                i, err := promptIndex(ctx, "Select device by its number (enclosed in []): ", len(devices))
+                if err != nil {
+                    return err
+                }
+                if i < 0 {
+                    return errors.New("no device selected")
+                }
+                device = &devices[i]
+            default:
+                return usageErrorf(ctx.Command, "missing <name|id|number>")
+            }
+
+            result := actionResult{Action: "transfer", Uri: device.Id, Name: device.Name}
+            if device.IsActive {
+                result.Message = "Already listening on " + device.Name
+                return ctx.Report(result)
+            }
+            if device.IsRestricted {
+                return errors.New("device " + device.Name + " does not accept commands from Spotify Web API")
+            }
+            if err := ctx.Client.TransferPlayback(ctx, device.Id, !noPlay); err != nil {
+                return err
+            }
+
+            result.Message = "Moved playback to " + device.Name
+            return ctx.Report(result)
+        },
+        Subcommands: []*Command{
+            deviceDefaultCommand(),
+            {
+                Name:        "list",
+                Aliases:     []string{"ls"},
+                Description: "List available devices",
+                Run: func(ctx *Context, args []string) error {
+                    devices, err := ctx.Client.Devices(ctx)
+                    if err != nil {
+                        return err
+                    }
+                    if len(devices) == 0 {
+                        return ErrNoDevices
+                    }
+                    return ctx.Render(devices, func(w io.Writer) {
+                        printNumbered(w, describeDevices(devices))
+                    })
+                },
+            },
+        },
+    }
+}
+
+func deviceDefaultCommand() *Command {
+    var appendLast, remove, clear bool
+    return &Command{
+        Name: "default",
+        Description: "Show or change devices used when no device is active\n" +
+            "Devices are tried in order, the first available one is used; if none of them is\n" +
+            "available, the first available device is. The device given becomes the first one to\n" +
+            "try unless --append is used. Devices that are not available right now can be given by\n" +
+            "their exact name. Profiles use devices of the default profile until they set their\n" +
+            "own; an emptied list, e.g. by --clear, stays empty.",
+        Args: []Arg{
+            {Name: "name|id|number", Description: "device to prefer", Optional: true, Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.BoolVar(&appendLast, "append", false, "try the device after the ones already set")
+            fs.BoolVar(&remove, "remove", false, "stop preferring the device")
+            fs.BoolVar(&clear, "clear", false, "forget all preferred devices")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if clear {
+                ctx.Config.setPreferredDevices(nil)
+                if err := saveConfig(ctx.Config); err != nil {
+                    return err
+                }
+                return renderPreferred(ctx, "")
+            }
+            if len(args) == 0 {
+                return renderPreferred(ctx, "")
+            }
+
+            name := strings.Join(args, " ")
+            if remove {
+                // preferred devices are matched the same way as available ones, so they
+                // can be given by number from the list too
+                stored := make([]spotify.Device, 0, len(ctx.Config.preferredDevices()))
+                for _, p := range ctx.Config.preferredDevices() {
+                    stored = append(stored, spotify.Device{Id: p, Name: p})
+                }
+                d, err := matchDevice(stored, name)
+                if err != nil {
+                    return errors.New("\"" + name + "\" is not one of preferred devices, see `spotify device default`")
+                }
+                name = d.Name
+            } else if d, err := resolveDevice(ctx, name); err == nil {
+                name = d.Name
+            } else {
+                // only a name can be saved for later, numbers are those of the list
+                var noDevice *noDeviceError
+                if _, numErr := strconv.Atoi(name); !errors.As(err, &noDevice) || numErr == nil {
+                    return err
+                }
+                _, _ = fmt.Fprintln(ctx.Stderr, "Device \""+name+"\" is not available right now, saving the name as is")
+            }
+
+            var preferred []string
+            for _, p := range ctx.Config.preferredDevices() {
+                if !strings.EqualFold(p, name) {
+                    preferred = append(preferred, p)
+                }
+            }
+            switch {
+            case remove:
+            case appendLast:
+                preferred = append(preferred, name)
+            default:
+                preferred = append([]string{name}, preferred...)
+            }
+            ctx.Config.setPreferredDevices(preferred)
+            if err := saveConfig(ctx.Config); err != nil {
+                return err
+            }
+
+            return renderPreferred(ctx, "Preferred devices:")
+        },
+    }
+}
+
+type preferredResult struct {
+    PreferredDevices []string `json:"preferred_devices"`
+}
+
+// renderPreferred renders preferred devices in effect, after the title in human output
+func renderPreferred(ctx *Context, title string) error {
+    preferred := append([]string{}, ctx.Config.preferredDevices()...)
+    return ctx.Render(preferredResult{PreferredDevices: preferred}, func(w io.Writer) {
+        if len(preferred) == 0 {
+            _, _ = fmt.Fprintln(w, "No preferred devices, the first available one is used")
+            return
+        }
+        if title != "" {
+            _, _ = fmt.Fprintln(w, title)
+        }
+        printNumbered(w, preferred)
+    })
+}
+
+// preferredDevice picks the first available of preferred devices, or just the first
+// available device if none of them is
+func preferredDevice(ctx *Context, devices []spotify.Device) *spotify.Device {
+    for _, p := range ctx.Config.preferredDevices() {
+        for i := range devices {
+            if devices[i].Id == p || strings.EqualFold(devices[i].Name, p) {
+                return &devices[i]
+            }
+        }
+    }
+    return &devices[0]
+}
+
+// describeDevices formats devices as "Desk (Speaker, 40%) active, private session"
+func describeDevices(devices []spotify.Device) []string {
+    lines := make([]string, 0, len(devices))
+    for _, d := range devices {
+        line := d.Name + " (" + d.Type + ", " + strconv.Itoa(d.VolumePercent) + "%)"
+        var flags []string
+        if d.IsActive {
+            flags = append(flags, "active")
+        }
+        if d.IsRestricted {
+            flags = append(flags, "restricted")
+        }
+        if d.IsPrivateSession {
+            flags = append(flags, "private session")
+        }
+        if len(flags) > 0 {
+            line += " " + strings.Join(flags, ", ")
+        }
+        lines = append(lines, line)
+    }
+    return lines
+}
+
+// resolveDevice finds available device the same way `device` command does
+func resolveDevice(ctx *Context, s string) (*spotify.Device, error) {
+    devices, err := ctx.Client.Devices(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return matchDevice(devices, s)
+}
+
+// matchDevice finds device by its number in the list, id or name. Names are matched
+// ignoring case, exact match goes first, then prefix, then any part of the name, then
+// letters in the same order ("dsk" for "Desk"); within the best level there must be
+// only one match.
+func matchDevice(devices []spotify.Device, s string) (*spotify.Device, error) {
+    if i, err := strconv.Atoi(s); err == nil && i >= 0 && i < len(devices) {
+        return &devices[i], nil
+    }
+    for i := range devices {
+        if devices[i].Id == s {
+            return &devices[i], nil
+        }
+    }
+
+    needle := strings.ToLower(s)
+    levels := []func(name string) bool{
+        func(name string) bool { return name == needle },
+        func(name string) bool { return strings.HasPrefix(name, needle) },
+        func(name string) bool { return strings.Contains(name, needle) },
+        func(name string) bool { return isSubsequence(needle, name) },
+    }
+    for _, matches := range levels {
+        var found []*spotify.Device
+        for i := range devices {
+            if matches(strings.ToLower(devices[i].Name)) {
+                found = append(found, &devices[i])
+            }
+        }
+        if len(found) == 1 {
+            return found[0], nil
+        }
+        if len(found) > 1 {
+            names := make([]string, 0, len(found))
+            for _, d := range found {
+                names = append(names, "\""+d.Name+"\"")
+            }
+            return nil, errors.New("\"" + s + "\" matches several devices: " + strings.Join(names, ", "))
+        }
+    }
+    return nil, &noDeviceError{name: s}
+}
+
+// noDeviceError means no available device matched, as opposed to several of them
+// matching or failing to list them
+type noDeviceError struct {
+    name string
+}
+
+func (e *noDeviceError) Error() string {
+    return "no device \"" + e.name + "\" available, see `spotify device list`"
+}
+
+// isSubsequence reports whether all letters of needle appear in s in the same order
+func isSubsequence(needle string, s string) bool {
+    for _, r := range s {
+        if len(needle) == 0 {
+            break
+        }
+        if strings.HasPrefix(needle, string(r)) {
+            needle = needle[len(string(r)):]
+        }
+    }
+    return len(needle) == 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "errors"
+    "strings"
+
+    "spotify/spotify"
+)
+
+// Exit codes, so that scripts can tell what went wrong without parsing messages
+const (
+    ExitOk              = 0
+    ExitError           = 1
+    ExitUsage           = 2
+    ExitUnauthorized    = 3
+    ExitNoActiveDevice  = 4
+    ExitPremiumRequired = 5
+    ExitRateLimited     = 6
+    ExitAPIError        = 7
+    ExitConflict        = 8
+    // ExitNegative is "no" of yes/no commands like `liked?`, which is not a failure
+    ExitNegative        = 9
+)
+
+var ErrNoDevices = errors.New("no available devices")
+var ErrNoClientId = errors.New("no client id configured")
+
+// errNegative is returned by yes/no commands after reporting "no", it only sets exit code
+var errNegative = errors.New("negative answer")
+
+// ScopeError is returned when stored credentials were not granted scopes the command
+// needs, which happens to logins done before the command was added
+type ScopeError struct {
+    Scopes []string
+}
+
+func (e *ScopeError) Error() string {
+    return "missing scope " + strings.Join(e.Scopes, " ")
+}
+
+// ConflictError is returned when playlist is no longer at the snapshot user expects it
+// to be, i.e. somebody else changed it
+type ConflictError struct {
+    Playlist string
+    Expected string
+    Actual   string
+}
+
+func (e *ConflictError) Error() string {
+    return "playlist " + e.Playlist + " is at snapshot " + e.Actual + ", not " + e.Expected
+}
+
+// describeError turns error returned by command into message for the user and exit code
+func describeError(err error) (message string, code int) {
+    var apiErr *spotify.APIError
+    var scopeErr *ScopeError
+    var conflictErr *ConflictError
+    switch {
+    case err == errNegative:
+        return "", ExitNegative
+    case errors.Is(err, ErrNotLoggedIn), errors.Is(err, spotify.ErrUnauthorized):
+        return "You need to log-in, run `spotify login`.", ExitUnauthorized
+    case errors.Is(err, ErrNoClientId):
+        return "No client id configured, run `spotify config set client_id <id>` or set SPOTIFY_CLI_CLIENT_ID.", ExitError
+    case errors.Is(err, ErrNoDevices):
+        return "No available devices. Open Spotify app on any of your devices!", ExitNoActiveDevice
+    case errors.As(err, &scopeErr):
+        scopes := strings.Join(scopeErr.Scopes, " ")
+        return "Your login does not allow this, run `spotify auth scopes " + scopes + "` to grant " + scopes + ".", ExitUnauthorized
+    case errors.As(err, &conflictErr):
+        return "Playlist " + conflictErr.Playlist + " was changed since snapshot " + conflictErr.Expected +
+            ", check it with `spotify playlist show` and try again.", ExitConflict
+    case errors.Is(err, spotify.ErrMissingScope):
+        return "Your login does not allow this, see `spotify auth scopes --help` to grant more scopes.", ExitUnauthorized
+    case errors.Is(err, spotify.ErrNoActiveDevice):
+        return "No active device. Start playback on any of your devices or pick one with `spotify device`.", ExitNoActiveDevice
+    case errors.Is(err, spotify.ErrPremiumRequired):
+        return "This needs Spotify Premium :(", ExitPremiumRequired
+    case errors.Is(err, spotify.ErrRateLimited):
+        return "Spotify says we are going too fast, try again in a bit.", ExitRateLimited
+    case errors.As(err, &apiErr):
+        return "Spotify returned an error: " + apiErr.Error(), ExitAPIError
+    }
+    return "Error: " + err.Error(), ExitError
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+// formatDuration formats milliseconds as "m:ss", or "h:mm:ss" for long episodes
+func formatDuration(ms int) string {
+    if ms < 0 {
+        ms = 0
+    }
+    seconds := ms / 1000
+    h, m, s := seconds/3600, seconds/60%60, seconds%60
+    if h > 0 {
+        return strconv.Itoa(h) + ":" + pad2(m) + ":" + pad2(s)
+    }
+    return strconv.Itoa(m) + ":" + pad2(s)
+}
+
+func pad2(n int) string {
+    if n < 10 {
+        return "0" + strconv.Itoa(n)
+    }
+    return strconv.Itoa(n)
+}
+
+// progressBar draws "[=====>     ]" of the given inner width
+func progressBar(progress int, duration int, width int) string {
+    filled := 0
+    if duration > 0 {
+        filled = progress * width / duration
+    }
+    if filled > width {
+        filled = width
+    }
+    bar := strings.Repeat("=", filled)
+    if filled < width {
+        bar += ">" + strings.Repeat(" ", width-filled-1)
+    }
+    return "[" + bar + "]"
+}
+
+func artistNames(artists []spotify.Artist) []string {
+    names := make([]string, 0, len(artists))
+    for _, a := range artists {
+        names = append(names, a.Name)
+    }
+    return names
+}
+
+func onOff(b bool) string {
+    if b {
+        return "on"
+    }
+    return "off"
+}
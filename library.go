@@ -0,0 +1,218 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "strconv"
+
+    "spotify/spotify"
+)
+
+const libraryReadScope = "user-library-read"
+const libraryModifyScope = "user-library-modify"
+
+func likeCommand() *Command {
+    return &Command{
+        Name:        "like",
+        Aliases:     []string{"save"},
+        Description: "Add tracks to Liked Songs, the playing one without argument",
+        Args: []Arg{
+            {Name: "uri|url", Description: "track URI or share link", Optional: true, Variadic: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            return changeLiked(ctx, args, true)
+        },
+    }
+}
+
+func unlikeCommand() *Command {
+    return &Command{
+        Name:        "unlike",
+        Aliases:     []string{"unsave"},
+        Description: "Remove tracks from Liked Songs, the playing one without argument",
+        Args: []Arg{
+            {Name: "uri|url", Description: "track URI or share link", Optional: true, Variadic: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            return changeLiked(ctx, args, false)
+        },
+    }
+}
+
+func changeLiked(ctx *Context, args []string, like bool) error {
+    if err := requireScopes(ctx, libraryModifyScope); err != nil {
+        return err
+    }
+    tracks, err := resolveTracks(ctx, args)
+    if err != nil {
+        return err
+    }
+    for start := 0; start < len(tracks); start += spotify.MaxLibraryIds {
+        end := start + spotify.MaxLibraryIds
+        if end > len(tracks) {
+            end = len(tracks)
+        }
+        ids := make([]string, 0, end-start)
+        for _, t := range tracks[start:end] {
+            ids = append(ids, t.Id)
+        }
+        if like {
+            err = ctx.Client.SaveTracks(ctx, ids)
+        } else {
+            err = ctx.Client.RemoveSavedTracks(ctx, ids)
+        }
+        if err != nil {
+            return err
+        }
+    }
+
+    result := actionResult{Action: "like", Message: "Liked "}
+    if !like {
+        result = actionResult{Action: "unlike", Message: "Removed from Liked Songs: "}
+    }
+    if len(tracks) == 1 {
+        result.Uri, result.Name = tracks[0].Uri, tracks[0].Name
+        result.Message += tracks[0].Name
+    } else {
+        result.Message += strconv.Itoa(len(tracks)) + " tracks"
+    }
+    return ctx.Report(result)
+}
+
+func likedCommand() *Command {
+    return &Command{
+        Name: "liked?",
+        Description: "Tell whether a track is in Liked Songs, the playing one without argument\n" +
+            "Exits with 0 if it is and 9 if it is not, so it can be used in scripts; other\n" +
+            "failures have their usual exit codes.",
+        Args: []Arg{
+            {Name: "uri|url", Description: "track URI or share link", Optional: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            if err := requireScopes(ctx, libraryReadScope); err != nil {
+                return err
+            }
+            tracks, err := resolveTracks(ctx, args)
+            if err != nil {
+                return err
+            }
+            saved, err := ctx.Client.SavedTracksContain(ctx, []string{tracks[0].Id})
+            if err != nil {
+                return err
+            }
+            result := likedResult{Uri: tracks[0].Uri, Name: tracks[0].Name, Liked: len(saved) > 0 && saved[0]}
+            err = ctx.Render(result, func(w io.Writer) {
+                if result.Liked {
+                    _, _ = fmt.Fprintln(w, result.Name+" is in Liked Songs")
+                } else {
+                    _, _ = fmt.Fprintln(w, result.Name+" is not in Liked Songs")
+                }
+            })
+            if err == nil && !result.Liked {
+                return errNegative
+            }
+            return err
+        },
+    }
+}
+
+type likedResult struct {
+    Uri   string `json:"uri"`
+    Name  string `json:"name"`
+    Liked bool   `json:"liked"`
+}
+
+// resolveTracks turns track URIs or links into tracks, or gives the playing track when
+// there are none. Names of given tracks are their URIs, it is not worth a request.
+func resolveTracks(ctx *Context, args []string) ([]spotify.Track, error) {
+    if len(args) == 0 {
+        item, err := playingItem(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if item.Type != "track" {
+            return nil, errors.New("only tracks can be liked, now playing is " + item.Type + " " + item.Name)
+        }
+        return []spotify.Track{*item}, nil
+    }
+
+    tracks := make([]spotify.Track, 0, len(args))
+    for _, arg := range args {
+        uri, err := spotify.ParseUri(arg)
+        kind, id := spotify.SplitUri(uri)
+        if err != nil || kind != "track" {
+            return nil, usageErrorf(ctx.Command, "%q is not a track URI or link", arg)
+        }
+        tracks = append(tracks, spotify.Track{Id: id, Uri: uri, Type: kind, Name: uri})
+    }
+    return tracks, nil
+}
+
+func libraryCommand() *Command {
+    return &Command{
+        Name:        "library",
+        Description: "Browse your library",
+        Subcommands: []*Command{
+            libraryTracksCommand(),
+        },
+    }
+}
+
+func libraryTracksCommand() *Command {
+    var limit, offset int
+    return &Command{
+        Name:        "tracks",
+        Description: "List Liked Songs, the most recently added first",
+        Flags: func(fs *flag.FlagSet) {
+            fs.IntVar(&limit, "limit", 20, "number of tracks to list, 1-50")
+            fs.IntVar(&offset, "offset", 0, "number of tracks to skip")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if limit < 1 || limit > 50 {
+                return usageErrorf(ctx.Command, "limit must be within 1-50")
+            }
+            if offset < 0 {
+                return usageErrorf(ctx.Command, "offset cannot be negative")
+            }
+            if err := requireScopes(ctx, libraryReadScope); err != nil {
+                return err
+            }
+            page, err := ctx.Client.SavedTracks(ctx, limit, offset)
+            if err != nil {
+                return err
+            }
+
+            rows := make([]savedTrackRow, 0, len(page.Items))
+            for i := range page.Items {
+                rows = append(rows, savedTrackRow{
+                    Position:   offset + i,
+                    AddedAt:    page.Items[i].AddedAt,
+                    itemResult: newItemResult(&page.Items[i].Track),
+                })
+            }
+            err = ctx.Render(rows, func(w io.Writer) {
+                if len(rows) == 0 {
+                    _, _ = fmt.Fprintln(w, "No liked songs here")
+                    return
+                }
+                for i, row := range rows {
+                    _, _ = fmt.Fprintln(w, "["+strconv.Itoa(row.Position)+"] "+describeItem(&page.Items[i].Track))
+                }
+            })
+            if err == nil && page.Next != "" && !ctx.machineOutput() {
+                printMoreHint(ctx, offset, len(rows), page.Total)
+            }
+            return err
+        },
+    }
+}
+
+type savedTrackRow struct {
+    // Position counts from 0 for the most recently added track, --offset skips that many
+    Position int    `json:"position"`
+    AddedAt  string `json:"added_at"`
+    *itemResult
+}
+
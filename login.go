@@ -0,0 +1,535 @@
+package main
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "html/template"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "os/signal"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "spotify/spotify"
+    "spotify/utils"
+)
+
+// loginTimeout is how long we wait for user to get through the login page
+const loginTimeout = 3 * time.Minute
+
+var callbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Spotify CLI</title>
+<style>
+body { font-family: sans-serif; text-align: center; margin-top: 20vh; color: #191414; }
+h1 { color: {{if .Ok}}#1db954{{else}}#e22134{{end}}; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+func loginCommand() *Command {
+    var options loginOptions
+    return &Command{
+        Name: "login",
+        Description: "Log in to Spotify, once; access token is refreshed automatically afterwards\n" +
+            "Login page is opened in browser and Spotify redirects back to a local server, so the\n" +
+            "redirect URI of your application must be http://127.0.0.1:<port>/ok. If the port is\n" +
+            "taken, a random one is used, Spotify allows that for loopback addresses. On remote\n" +
+            "machines use --headless: open the printed link anywhere and paste back the address\n" +
+            "you were redirected to.",
+        Flags: options.flags,
+        Run: func(ctx *Context, args []string) error {
+            if err := options.login(ctx, ctx.Config.scopes()); err != nil {
+                return err
+            }
+            return authStatusResult(ctx, "You are successfully logged in. Lets go play some music!")
+        },
+    }
+}
+
+// loginOptions are flags of commands going through the login page
+type loginOptions struct {
+    headless  bool
+    noBrowser bool
+    port      string
+}
+
+func (o *loginOptions) flags(fs *flag.FlagSet) {
+    fs.BoolVar(&o.headless, "headless", false, "do not start local server, paste the redirected address instead")
+    fs.BoolVar(&o.noBrowser, "no-browser", false, "print login link instead of opening browser")
+    fs.StringVar(&o.port, "port", "", "port of redirect URI, overrides `port` setting")
+}
+
+// login asks user for the scopes on the login page and stores credentials of the profile
+func (o *loginOptions) login(ctx *Context, scopes string) error {
+    if ctx.Config.clientId() == "" {
+        return ErrNoClientId
+    }
+    port := o.port
+    if port == "" {
+        port = ctx.Config.port()
+    } else if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+        return usageErrorf(ctx.Command, "port must be a number from 1 to 65535")
+    }
+
+    var err error
+    if o.headless {
+        err = pasteLogin(ctx, port, scopes)
+    } else {
+        err = callbackLogin(ctx, port, scopes, !o.noBrowser)
+    }
+    if err != nil {
+        return errors.New("login failed, " + err.Error())
+    }
+    return nil
+}
+
+// loginRequest is one attempt to log in, it knows how to build the login link and
+// what to expect back
+type loginRequest struct {
+    config      *Config
+    store       *CredentialStore
+    scopes      string
+    redirectUri string
+    verifier    string
+    state       string
+}
+
+func newLoginRequest(ctx *Context, port string, scopes string) (*loginRequest, error) {
+    verifier, err := newCodeVerifier()
+    if err != nil {
+        return nil, errors.New("cannot generate login challenge: " + err.Error())
+    }
+    state, err := newState()
+    if err != nil {
+        return nil, errors.New("cannot generate login state: " + err.Error())
+    }
+    return &loginRequest{
+        config:      ctx.Config,
+        store:       ctx.Store,
+        scopes:      scopes,
+        redirectUri: "http://" + callbackHost + ":" + port + "/ok",
+        verifier:    verifier,
+        state:       state,
+    }, nil
+}
+
+func (l *loginRequest) url() string {
+    return authorizeUrl(l.config, l.scopes, l.redirectUri, codeChallenge(l.verifier), l.state)
+}
+
+// complete checks query of the redirect and logs in with the code from it
+func (l *loginRequest) complete(query url.Values) error {
+    if e := query.Get("error"); e != "" {
+        return errors.New("spotify refused authorization: " + e)
+    }
+    if query.Get("state") != l.state {
+        return errors.New("state does not match, the redirect is not an answer to this login")
+    }
+    code := query.Get("code")
+    if code == "" {
+        return errors.New("no authorization code provided from Spotify")
+    }
+    return completeLogin(l.config, l.store, code, l.verifier, l.redirectUri)
+}
+
+// callbackServer receives redirect from Spotify, only the first one with the state of
+// this login counts; anything else, e.g. a stale tab of an earlier login, is turned
+// down and the login keeps waiting
+type callbackServer struct {
+    login   *loginRequest
+    once    sync.Once
+    results chan error
+}
+
+func (s *callbackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/ok" {
+        http.NotFound(w, r)
+        return
+    }
+    if r.URL.Query().Get("state") != s.login.state {
+        renderCallbackPage(w, http.StatusBadRequest, false, "Not this login",
+            "This page is not an answer to the login in progress, open the link printed in your terminal.")
+        return
+    }
+    handled := false
+    s.once.Do(func() {
+        handled = true
+        err := s.login.complete(r.URL.Query())
+        if err != nil {
+            renderCallbackPage(w, http.StatusBadRequest, false, "Login failed", err.Error()+". Rerun login command.")
+        } else {
+            renderCallbackPage(w, http.StatusOK, true, "Success!", "You can close this window and use CLI.")
+        }
+        s.results <- err
+    })
+    if !handled {
+        renderCallbackPage(w, http.StatusConflict, false, "Already handled", "This login is already over, check your terminal.")
+    }
+}
+
+func renderCallbackPage(w http.ResponseWriter, status int, ok bool, title string, message string) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(status)
+    _ = callbackPage.Execute(w, struct {
+        Ok      bool
+        Title   string
+        Message string
+    }{ok, title, message})
+}
+
+// callbackHost is where the local server listens for the redirect. Spotify does not
+// accept localhost in redirect URIs and lets the port vary only for loopback addresses.
+const callbackHost = "127.0.0.1"
+
+// listenCallback listens on the port, or on a random one if the port is taken
+func listenCallback(ctx *Context, port string) (net.Listener, error) {
+    listener, err := net.Listen("tcp", callbackHost+":"+port)
+    if err == nil {
+        return listener, nil
+    }
+    listener, fallbackErr := net.Listen("tcp", callbackHost+":0")
+    if fallbackErr != nil {
+        return nil, errors.New("cannot start local server: " + err.Error())
+    }
+    _, _ = fmt.Fprintln(ctx.Stderr, "Port "+port+" is not available ("+err.Error()+"), using "+
+        strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)+" instead.")
+    return listener, nil
+}
+
+// callbackLogin waits for Spotify to redirect the browser back to local server with
+// authorization code
+func callbackLogin(ctx *Context, port string, scopes string, openBrowser bool) error {
+    listener, err := listenCallback(ctx, port)
+    if err != nil {
+        return err
+    }
+    login, err := newLoginRequest(ctx, strconv.Itoa(listener.Addr().(*net.TCPAddr).Port), scopes)
+    if err != nil {
+        _ = listener.Close()
+        return err
+    }
+
+    callback := &callbackServer{login: login, results: make(chan error, 1)}
+    server := &http.Server{
+        Handler:      callback,
+        ReadTimeout:  10 * time.Second,
+        WriteTimeout: 30 * time.Second,
+    }
+    serveErr := make(chan error, 1)
+    go func() {
+        serveErr <- server.Serve(listener)
+    }()
+    defer func() {
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        defer cancel()
+        _ = server.Shutdown(shutdownCtx)
+    }()
+
+    fullUrl := login.url()
+    if openBrowser {
+        _, _ = fmt.Fprintln(ctx.Stderr, "Opening authentication page in browser...")
+        if opened := browser.Open(fullUrl); !opened {
+            _, _ = fmt.Fprintln(ctx.Stderr, "Cannot open browser :(")
+            openBrowser = false
+        }
+    }
+    if !openBrowser {
+        _, _ = fmt.Fprintln(ctx.Stderr, "Please open this link in your browser: "+fullUrl)
+        _, _ = fmt.Fprintln(ctx.Stderr, "If the browser is on another machine, rerun with --headless instead.")
+    }
+
+    interrupted := make(chan os.Signal, 1)
+    signal.Notify(interrupted, os.Interrupt)
+    defer signal.Stop(interrupted)
+
+    timer := time.NewTimer(loginTimeout)
+    defer timer.Stop()
+
+    select {
+    case err := <-callback.results:
+        return err
+    case err := <-serveErr:
+        return errors.New("local server stopped: " + err.Error())
+    case <-interrupted:
+        return errors.New("interrupted")
+    case <-timer.C:
+        return errors.New("timeout of " + strconv.Itoa(int(loginTimeout.Seconds())) + " seconds reached on authorization")
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// pasteLogin lets user log in with browser on another machine: Spotify redirects there
+// to a page that does not load, but its address has the authorization code in it
+func pasteLogin(ctx *Context, port string, scopes string) error {
+    login, err := newLoginRequest(ctx, port, scopes)
+    if err != nil {
+        return err
+    }
+    _, _ = fmt.Fprintln(ctx.Stderr, "Open this link in a browser on any machine and log in:")
+    _, _ = fmt.Fprintln(ctx.Stderr)
+    _, _ = fmt.Fprintln(ctx.Stderr, "    "+login.url())
+    _, _ = fmt.Fprintln(ctx.Stderr)
+    _, _ = fmt.Fprintln(ctx.Stderr, "You will be redirected to "+login.redirectUri+"?code=... which will not load, that is fine.")
+    _, _ = fmt.Fprint(ctx.Stderr, "Paste the address of that page (or just the code): ")
+
+    line, err := bufio.NewReader(ctx.Stdin).ReadString('\n')
+    if err != nil && line == "" {
+        return errors.New("nothing was pasted")
+    }
+    query, err := parseRedirect(line)
+    if err != nil {
+        return err
+    }
+    // bare code has no state to check, it cannot come from anyone else's redirect
+    // as user pasted it
+    if _, ok := query["state"]; !ok {
+        query.Set("state", login.state)
+    }
+    return login.complete(query)
+}
+
+// parseRedirect reads query of pasted redirect address; the query alone or just the
+// code are fine too
+func parseRedirect(s string) (url.Values, error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return nil, errors.New("nothing was pasted")
+    }
+    if !strings.Contains(s, "=") {
+        return url.Values{"code": {s}}, nil
+    }
+    if i := strings.Index(s, "?"); i >= 0 {
+        s = s[i+1:]
+    }
+    query, err := url.ParseQuery(strings.SplitN(s, "#", 2)[0])
+    if err != nil {
+        return nil, errors.New("cannot read pasted address: " + err.Error())
+    }
+    return query, nil
+}
+
+func logoutCommand() *Command {
+    return &Command{
+        Name:        "logout",
+        Description: "Forget credentials of the profile in use\nSpotify still lists the application as allowed, remove it at https://www.spotify.com/account/apps/ to revoke access completely.",
+        Run: func(ctx *Context, args []string) error {
+            result := actionResult{Action: "logout", Name: ctx.Config.active}
+            err := ctx.Store.Delete()
+            if errors.Is(err, ErrNotLoggedIn) {
+                result.Message = "Not logged in (profile " + ctx.Config.active + ")"
+                return ctx.Report(result)
+            }
+            if err != nil {
+                return err
+            }
+            result.Message = "Logged out (profile " + ctx.Config.active + ")"
+            return ctx.Report(result)
+        },
+    }
+}
+
+func authCommand() *Command {
+    return &Command{
+        Name:        "auth",
+        Description: "Show or extend login of the profile in use",
+        Run:         authStatus,
+        Subcommands: []*Command{
+            {
+                Name:        "status",
+                Description: "Show account, granted scopes and token expiry; exits with 3 when not logged in",
+                Run:         authStatus,
+            },
+            authScopesCommand(),
+        },
+    }
+}
+
+// authResult is what we know about login of the profile
+type authResult struct {
+    Profile   string   `json:"profile"`
+    LoggedIn  bool     `json:"logged_in"`
+    AccountId string   `json:"account_id"`
+    Scopes    []string `json:"scopes"`
+    // MissingScopes are configured scopes that were not granted
+    MissingScopes []string   `json:"missing_scopes"`
+    Expiry        *time.Time `json:"expiry"`
+    Expired       bool       `json:"expired"`
+    Path          string     `json:"path"`
+}
+
+func authStatus(ctx *Context, args []string) error {
+    return authStatusResult(ctx, "")
+}
+
+// authStatusResult renders login status, human output starts with the title if any
+func authStatusResult(ctx *Context, title string) error {
+    c, err := ctx.Store.Load()
+    if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+        return err
+    }
+    result := authResult{Profile: ctx.Config.active, Scopes: []string{}, MissingScopes: []string{}, Path: ctx.Store.Path}
+    if c == nil {
+        renderErr := ctx.Render(result, func(w io.Writer) {
+            _, _ = fmt.Fprintln(w, "Not logged in (profile "+ctx.Config.active+"), credentials would be stored in "+ctx.Store.Path)
+        })
+        if renderErr != nil {
+            return renderErr
+        }
+        return err
+    }
+    result.LoggedIn = true
+    result.AccountId = c.AccountId
+    result.Scopes = append(result.Scopes, c.Scopes...)
+    result.MissingScopes = append(result.MissingScopes, missingScopes(c.Scopes, strings.Fields(ctx.Config.scopes()))...)
+    result.Expiry = &c.Expiry
+    result.Expired = c.Expired()
+
+    return ctx.Render(result, func(w io.Writer) {
+        if title != "" {
+            _, _ = fmt.Fprintln(w, title)
+            return
+        }
+        expiry := "in " + time.Until(c.Expiry).Round(time.Second).String()
+        if c.Expired() {
+            expiry = "expired, refreshed automatically on next use"
+        }
+        account := c.AccountId
+        if account == "" {
+            account = "unknown"
+        }
+        rows := [][2]string{
+            {"Profile:", ctx.Config.active},
+            {"Account:", account},
+            {"Scopes:", strings.Join(c.Scopes, " ")},
+            {"Expires:", expiry + " (" + c.Expiry.Local().Format("2006-01-02 15:04:05") + ")"},
+            {"Stored in:", ctx.Store.Path},
+        }
+        if len(result.MissingScopes) > 0 {
+            rows = append(rows, [2]string{"Not granted:", strings.Join(result.MissingScopes, " ") + " (run `spotify auth scopes`)"})
+        }
+        for _, row := range rows {
+            _, _ = fmt.Fprintf(w, "%-12s %s\n", row[0], row[1])
+        }
+    })
+}
+
+func authScopesCommand() *Command {
+    var options loginOptions
+    return &Command{
+        Name: "scopes",
+        Description: "List granted scopes or ask for more\n" +
+            "With scopes given, goes through the login page again asking for them together with\n" +
+            "the already granted and configured ones, see `spotify login --help`.",
+        Args: []Arg{
+            {Name: "scope", Description: "scope to ask for, e.g. user-library-read", Optional: true, Variadic: true},
+        },
+        Flags: options.flags,
+        Run: func(ctx *Context, args []string) error {
+            c, err := ctx.Store.Load()
+            if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+                return err
+            }
+            var granted []string
+            if c != nil {
+                granted = c.Scopes
+            }
+            if len(args) == 0 {
+                if c == nil {
+                    return err
+                }
+                return ctx.Render(granted, func(w io.Writer) {
+                    for _, scope := range granted {
+                        _, _ = fmt.Fprintln(w, scope)
+                    }
+                })
+            }
+
+            wanted := append(append(strings.Fields(ctx.Config.scopes()), granted...), args...)
+            scopes := append([]string{}, granted...)
+            scopes = append(scopes, missingScopes(scopes, wanted)...)
+            if err := options.login(ctx, strings.Join(scopes, " ")); err != nil {
+                return err
+            }
+
+            fresh, err := ctx.Store.Load()
+            if err != nil {
+                return err
+            }
+            if missing := missingScopes(fresh.Scopes, args); len(missing) > 0 {
+                return errors.New("spotify did not grant " + strings.Join(missing, " "))
+            }
+            return ctx.Render(fresh.Scopes, func(w io.Writer) {
+                _, _ = fmt.Fprintln(w, "Granted scopes: "+strings.Join(fresh.Scopes, " "))
+            })
+        },
+    }
+}
+
+// missingScopes returns wanted scopes that are not in granted, without duplicates
+func missingScopes(granted []string, wanted []string) []string {
+    have := map[string]bool{}
+    for _, scope := range granted {
+        have[scope] = true
+    }
+    var missing []string
+    for _, scope := range wanted {
+        if !have[scope] {
+            missing = append(missing, scope)
+            have[scope] = true
+        }
+    }
+    return missing
+}
+
+// requireScopes fails with ScopeError unless stored credentials were granted all of the
+// scopes. Credentials which do not record scopes are let through, API tells if they lack any.
+func requireScopes(ctx *Context, scopes ...string) error {
+    c, err := ctx.Store.Load()
+    if err != nil {
+        return err
+    }
+    if len(c.Scopes) == 0 {
+        return nil
+    }
+    if missing := missingScopes(c.Scopes, scopes); len(missing) > 0 {
+        return &ScopeError{Scopes: missing}
+    }
+    return nil
+}
+
+// completeLogin exchanges authorization code for credentials, finds out whose they are
+// and stores them
+func completeLogin(config *Config, store *CredentialStore, code string, verifier string, redirectUri string) error {
+    c, err := exchangeCode(config, code, verifier, redirectUri)
+    if err != nil {
+        return errors.New("cannot exchange authorization code: " + err.Error())
+    }
+    client := spotify.NewClient(spotify.StaticToken(c.AccessToken))
+    client.BaseUrl = config.apiUrl()
+    user, err := client.CurrentUser(context.Background())
+    if err != nil {
+        return errors.New("cannot get account: " + err.Error())
+    }
+    c.AccountId = user.Id
+    if err := store.Save(c); err != nil {
+        return errors.New("cannot save token: " + err.Error())
+    }
+    return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+    "fmt"
+    "io"
+
+    "spotify/spotify"
+)
+
+// repeatCycle is the order repeat modes are switched in, same as in Spotify apps
+var repeatCycle = map[string]string{
+    "off":     "context",
+    "context": "track",
+    "track":   "off",
+}
+
+// modesResult is what shuffle and repeat commands set
+type modesResult struct {
+    Shuffle *bool  `json:"shuffle,omitempty"`
+    Repeat  string `json:"repeat,omitempty"`
+}
+
+func shuffleCommand() *Command {
+    return &Command{
+        Name:        "shuffle",
+        Description: "Turn shuffle on or off, toggles it without argument",
+        Args: []Arg{
+            {Name: "on|off|toggle", Description: "shuffle state to set, toggle by default", Optional: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            mode := "toggle"
+            if len(args) > 0 {
+                mode = args[0]
+            }
+
+            var shuffle bool
+            switch mode {
+            case "on", "off":
+                shuffle = mode == "on"
+            case "toggle":
+                state, err := currentPlayback(ctx)
+                if err != nil {
+                    return err
+                }
+                shuffle = !state.ShuffleState
+            default:
+                return usageErrorf(ctx.Command, "unknown shuffle state %q", mode)
+            }
+
+            err := onDevice(ctx, func(deviceId string) error { return ctx.Client.SetShuffle(ctx, deviceId, shuffle) })
+            if err != nil {
+                return err
+            }
+
+            return ctx.Render(modesResult{Shuffle: &shuffle}, func(w io.Writer) {
+                _, _ = fmt.Fprintln(w, "Shuffle is "+onOff(shuffle))
+            })
+        },
+    }
+}
+
+func repeatCommand() *Command {
+    return &Command{
+        Name: "repeat",
+        Description: "Set repeat mode, cycles through the modes without argument\n" +
+            "Cycle goes off → context → track → off, as in Spotify apps.",
+        Args: []Arg{
+            {Name: "off|track|context|cycle", Description: "repeat mode to set, cycle by default", Optional: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            mode := "cycle"
+            if len(args) > 0 {
+                mode = args[0]
+            }
+
+            switch mode {
+            case "off", "track", "context":
+            case "cycle":
+                state, err := currentPlayback(ctx)
+                if err != nil {
+                    return err
+                }
+                mode = repeatCycle[state.RepeatState]
+                if mode == "" {
+                    mode = "off"
+                }
+            default:
+                return usageErrorf(ctx.Command, "unknown repeat mode %q", mode)
+            }
+
+            err := onDevice(ctx, func(deviceId string) error { return ctx.Client.SetRepeat(ctx, deviceId, mode) })
+            if err != nil {
+                return err
+            }
+
+            return ctx.Render(modesResult{Repeat: mode}, func(w io.Writer) {
+                _, _ = fmt.Fprintln(w, "Repeat is "+mode)
+            })
+        },
+    }
+}
+
+// currentPlayback is playback state for commands that make no sense without one
+func currentPlayback(ctx *Context) (*spotify.PlaybackState, error) {
+    state, err := ctx.Client.PlaybackState(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if state == nil {
+        return nil, spotify.ErrNoActiveDevice
+    }
+    return state, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// Output formats, chosen by the global --output flag
+const (
+    OutputHuman = "human"
+    OutputJson  = "json"
+    OutputYaml  = "yaml"
+    OutputTsv   = "tsv"
+)
+
+var outputFormats = []string{OutputHuman, OutputJson, OutputYaml, OutputTsv}
+
+func checkOutputFormat(format string) error {
+    for _, f := range outputFormats {
+        if f == format {
+            return nil
+        }
+    }
+    return errors.New("unknown output format \"" + format + "\", expected one of " + strings.Join(outputFormats, ", "))
+}
+
+// Render writes result of the command to stdout in the format user asked for. Value is
+// what machine formats get, it is encoded through encoding/json so json tags decide the
+// field names; --format template gets it as is; human writes it for people.
+func (ctx *Context) Render(value interface{}, human func(w io.Writer)) error {
+    if ctx.Format != nil {
+        content, err := executeFormat(ctx.Format, value)
+        if err != nil {
+            return err
+        }
+        _, err = ctx.Stdout.Write(content)
+        return err
+    }
+    if ctx.Output == "" || ctx.Output == OutputHuman {
+        human(ctx.Stdout)
+        return nil
+    }
+    content, err := json.Marshal(value)
+    if err != nil {
+        return err
+    }
+    if ctx.Output == OutputJson {
+        var indented bytes.Buffer
+        if err := json.Indent(&indented, content, "", "  "); err != nil {
+            return err
+        }
+        indented.WriteByte('\n')
+        _, err = ctx.Stdout.Write(indented.Bytes())
+        return err
+    }
+
+    if t, ok := value.(tabular); ok && ctx.Output == OutputTsv {
+        if content, err = json.Marshal(t.Rows()); err != nil {
+            return err
+        }
+    }
+    tree, err := decodeOrdered(content)
+    if err != nil {
+        return err
+    }
+    var out bytes.Buffer
+    if ctx.Output == OutputYaml {
+        writeYaml(&out, tree, 0)
+    } else {
+        writeTsv(&out, tree)
+    }
+    _, err = ctx.Stdout.Write(out.Bytes())
+    return err
+}
+
+// tabular is implemented by results that are better shown as rows of a different value
+// in TSV, e.g. one row per item instead of a single row with list of items
+type tabular interface {
+    Rows() interface{}
+}
+
+// actionResult is the result of commands which only change something, Message is what
+// human output shows
+type actionResult struct {
+    Action  string `json:"action"`
+    Uri     string `json:"uri,omitempty"`
+    Name    string `json:"name,omitempty"`
+    Message string `json:"message"`
+}
+
+// Report renders result of the action
+func (ctx *Context) Report(result actionResult) error {
+    return ctx.Render(result, func(w io.Writer) {
+        _, _ = fmt.Fprintln(w, result.Message)
+    })
+}
+
+// machineOutput tells whether output goes to a program, so there is nobody to ask
+// questions to
+func (ctx *Context) machineOutput() bool {
+    return ctx.Format != nil || ctx.Output != "" && ctx.Output != OutputHuman
+}
+
+// field is a member of JSON object, objects are kept as lists of fields because maps
+// would lose the order of struct fields
+type field struct {
+    Key   string
+    Value interface{}
+}
+
+// decodeOrdered decodes JSON into []field for objects, []interface{} for arrays and
+// json.Number, string, bool or nil for the rest
+func decodeOrdered(content []byte) (interface{}, error) {
+    dec := json.NewDecoder(bytes.NewReader(content))
+    dec.UseNumber()
+    return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+    token, err := dec.Token()
+    if err != nil {
+        return nil, err
+    }
+    switch token {
+    case json.Delim('{'):
+        fields := []field{}
+        for dec.More() {
+            key, err := dec.Token()
+            if err != nil {
+                return nil, err
+            }
+            value, err := decodeValue(dec)
+            if err != nil {
+                return nil, err
+            }
+            fields = append(fields, field{Key: key.(string), Value: value})
+        }
+        _, err = dec.Token()
+        return fields, err
+    case json.Delim('['):
+        items := []interface{}{}
+        for dec.More() {
+            item, err := decodeValue(dec)
+            if err != nil {
+                return nil, err
+            }
+            items = append(items, item)
+        }
+        _, err = dec.Token()
+        return items, err
+    }
+    return token, nil
+}
+
+func isScalar(v interface{}) bool {
+    switch v := v.(type) {
+    case []field:
+        return len(v) == 0
+    case []interface{}:
+        return len(v) == 0
+    }
+    return true
+}
+
+func scalarString(v interface{}) string {
+    switch v := v.(type) {
+    case nil:
+        return ""
+    case string:
+        return v
+    case bool:
+        return strconv.FormatBool(v)
+    case json.Number:
+        return v.String()
+    }
+    content, _ := json.Marshal(v)
+    return string(content)
+}
+
+// yamlPlain are strings that YAML reads back as strings when written unquoted: starting
+// with a letter rules out numbers, dates, .inf and the like, the rest rules out syntax
+var yamlPlain = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_./ (),'+-]*$`)
+var yamlReserved = regexp.MustCompile(`^(?i:true|false|yes|no|on|off|null|y|n)$`)
+
+func yamlScalar(v interface{}) string {
+    switch v := v.(type) {
+    case nil:
+        return "null"
+    case []field:
+        return "{}"
+    case []interface{}:
+        return "[]"
+    case string:
+        if !yamlPlain.MatchString(v) || strings.HasSuffix(v, " ") || yamlReserved.MatchString(v) {
+            return strconv.Quote(v)
+        }
+        return v
+    }
+    return scalarString(v)
+}
+
+func writeYaml(w *bytes.Buffer, v interface{}, indent int) {
+    prefix := strings.Repeat("  ", indent)
+    switch v := v.(type) {
+    case []field:
+        if len(v) == 0 {
+            w.WriteString(prefix + "{}\n")
+        }
+        for _, f := range v {
+            if isScalar(f.Value) {
+                w.WriteString(prefix + yamlScalar(f.Key) + ": " + yamlScalar(f.Value) + "\n")
+                continue
+            }
+            w.WriteString(prefix + yamlScalar(f.Key) + ":\n")
+            writeYaml(w, f.Value, indent+1)
+        }
+    case []interface{}:
+        if len(v) == 0 {
+            w.WriteString(prefix + "[]\n")
+        }
+        for _, item := range v {
+            if isScalar(item) {
+                w.WriteString(prefix + "- " + yamlScalar(item) + "\n")
+                continue
+            }
+            // nested block goes on the same line as the dash
+            var nested bytes.Buffer
+            writeYaml(&nested, item, indent+1)
+            w.WriteString(prefix + "- " + strings.TrimPrefix(nested.String(), prefix+"  "))
+        }
+    default:
+        w.WriteString(prefix + yamlScalar(v) + "\n")
+    }
+}
+
+// writeTsv writes header and a row for every item of a list, or a single row for
+// anything else. Nested objects become dotted columns, lists are JSON arrays as their
+// items may contain any separator.
+func writeTsv(w *bytes.Buffer, v interface{}) {
+    items, ok := v.([]interface{})
+    if !ok {
+        items = []interface{}{v}
+    }
+    var columns []string
+    seen := map[string]bool{}
+    var rows []map[string]string
+    for _, item := range items {
+        row := map[string]string{}
+        for _, f := range flatten("", item, nil) {
+            if !seen[f.Key] {
+                seen[f.Key] = true
+                columns = append(columns, f.Key)
+            }
+            row[f.Key] = f.Value.(string)
+        }
+        rows = append(rows, row)
+    }
+    if len(columns) == 0 {
+        return
+    }
+
+    w.WriteString(strings.Join(columns, "\t") + "\n")
+    clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+    for _, row := range rows {
+        values := make([]string, 0, len(columns))
+        for _, c := range columns {
+            values = append(values, clean.Replace(row[c]))
+        }
+        w.WriteString(strings.Join(values, "\t") + "\n")
+    }
+}
+
+func flatten(prefix string, v interface{}, out []field) []field {
+    key := prefix
+    if key == "" {
+        key = "value"
+    }
+    switch v := v.(type) {
+    case []field:
+        for _, f := range v {
+            name := f.Key
+            if prefix != "" {
+                name = prefix + "." + f.Key
+            }
+            out = flatten(name, f.Value, out)
+        }
+        return out
+    case []interface{}:
+        content, _ := json.Marshal(plain(v))
+        return append(out, field{Key: key, Value: string(content)})
+    }
+    return append(out, field{Key: key, Value: scalarString(v)})
+}
+
+// plain turns ordered tree back to something encoding/json writes in the same order
+func plain(v interface{}) interface{} {
+    switch v := v.(type) {
+    case []field:
+        var b bytes.Buffer
+        b.WriteByte('{')
+        for i, f := range v {
+            if i > 0 {
+                b.WriteByte(',')
+            }
+            key, _ := json.Marshal(f.Key)
+            value, _ := json.Marshal(plain(f.Value))
+            b.Write(key)
+            b.WriteByte(':')
+            b.Write(value)
+        }
+        b.WriteByte('}')
+        return json.RawMessage(b.Bytes())
+    case []interface{}:
+        items := make([]interface{}, 0, len(v))
+        for _, item := range v {
+            items = append(items, plain(item))
+        }
+        return items
+    }
+    return v
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestWriteYaml(t *testing.T) {
+    tests := []struct {
+        name string
+        json string
+        want string
+    }{
+        {"plain strings", `{"name":"Song One","uri":"spotify:track:t1"}`, "name: Song One\nuri: \"spotify:track:t1\"\n"},
+        {"numbers and bools", `{"volume":40,"shuffle":true,"device":null}`, "volume: 40\nshuffle: true\ndevice: null\n"},
+        {"date", `{"name":"2024-01-01"}`, "name: \"2024-01-01\"\n"},
+        {"hex", `{"name":"0x10"}`, "name: \"0x10\"\n"},
+        {"infinity", `{"name":".inf"}`, "name: \".inf\"\n"},
+        {"number", `{"name":"1e3"}`, "name: \"1e3\"\n"},
+        {"reserved word", `{"name":"Yes"}`, "name: \"Yes\"\n"},
+        {"trailing space", `{"name":"Song "}`, "name: \"Song \"\n"},
+        {"syntax", `{"name":"a: b #c"}`, "name: \"a: b #c\"\n"},
+        {"empty", `{"name":"","items":[],"device":{}}`, "name: \"\"\nitems: []\ndevice: {}\n"},
+        {"nested", `{"item":{"name":"Song","artists":["A, B","C"]}}`, "item:\n  name: Song\n  artists:\n    - A, B\n    - C\n"},
+        {"list of objects", `[{"name":"Desk","volume":40},{"name":"Phone","volume":70}]`,
+            "- name: Desk\n  volume: 40\n- name: Phone\n  volume: 70\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tree, err := decodeOrdered([]byte(tt.json))
+            if err != nil {
+                t.Fatal(err)
+            }
+            var out bytes.Buffer
+            writeYaml(&out, tree, 0)
+            if out.String() != tt.want {
+                t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+            }
+        })
+    }
+}
+
+func TestWriteTsv(t *testing.T) {
+    tests := []struct {
+        name string
+        json string
+        want string
+    }{
+        {"single object", `{"name":"Desk","volume":40}`, "name\tvolume\nDesk\t40\n"},
+        {"rows", `[{"name":"Desk","volume":40},{"name":"Phone","volume":70}]`, "name\tvolume\nDesk\t40\nPhone\t70\n"},
+        {"nested", `{"item":{"name":"Song"},"device":null}`, "item.name\tdevice\nSong\t\n"},
+        {"list with separators", `{"artists":["A, B","C"]}`, "artists\n[\"A, B\",\"C\"]\n"},
+        {"list of objects", `{"items":[{"id":1}]}`, "items\n[{\"id\":1}]\n"},
+        {"tabs and newlines", `{"name":"a\tb\nc"}`, "name\na b c\n"},
+        {"columns of later rows", `[{"a":"1"},{"a":"2","b":"3"}]`, "a\tb\n1\t\n2\t3\n"},
+        {"empty list", `[]`, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tree, err := decodeOrdered([]byte(tt.json))
+            if err != nil {
+                t.Fatal(err)
+            }
+            var out bytes.Buffer
+            writeTsv(&out, tree)
+            if out.String() != tt.want {
+                t.Errorf("got\n%q\nwant\n%q", out.String(), tt.want)
+            }
+        })
+    }
+}
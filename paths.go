@@ -0,0 +1,53 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "runtime"
+)
+
+const AppDirName = "spotify-cli"
+
+// stateDir is where we keep things that are not configuration but must survive between
+// runs, like credentials. Follows XDG base directory spec on unix-like systems and falls
+// back to the platform config directory elsewhere.
+func stateDir() (string, error) {
+    if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+        return filepath.Join(dir, AppDirName), nil
+    }
+    if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+        dir, err := os.UserConfigDir()
+        if err != nil {
+            return "", err
+        }
+        return filepath.Join(dir, AppDirName), nil
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(home, ".local", "state", AppDirName), nil
+}
+
+// configDir is where configuration lives, $XDG_CONFIG_HOME/spotify-cli on unix-like
+// systems (~/.config by default) and the platform config directory elsewhere
+func configDir() (string, error) {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, AppDirName), nil
+}
+
+// profileStateDir is state directory of the profile, the default profile uses stateDir
+// itself so that credentials stored before profiles existed keep working
+func profileStateDir(profile string) (string, error) {
+    dir, err := stateDir()
+    if err != nil {
+        return "", err
+    }
+    if profile == DefaultProfile {
+        return dir, nil
+    }
+    return filepath.Join(dir, "profiles", profile), nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "math/rand"
+    "strconv"
+    "strings"
+    "time"
+
+    "spotify/spotify"
+)
+
+// togglePlay pauses playback if something is playing and resumes it otherwise
+func togglePlay(ctx *Context, args []string) error {
+    state, err := ctx.Client.PlaybackState(ctx)
+    if err != nil {
+        return err
+    }
+    if state != nil && state.IsPlaying {
+        if err := ctx.Client.Pause(ctx, ""); err != nil {
+            return err
+        }
+        return ctx.Report(actionResult{Action: "pause", Message: "Paused playback"})
+    }
+
+    err = onDevice(ctx, func(deviceId string) error {
+        return ctx.Client.Play(ctx, spotify.PlayOptions{DeviceId: deviceId})
+    })
+    if err != nil {
+        return err
+    }
+
+    return ctx.Report(actionResult{Action: "resume", Message: "Resumed playback"})
+}
+
+func nextTrack(ctx *Context, args []string) error {
+    if err := onDevice(ctx, func(deviceId string) error { return ctx.Client.Next(ctx, deviceId) }); err != nil {
+        return err
+    }
+
+    return ctx.Report(actionResult{Action: "next", Message: "Playing next"})
+}
+
+func previousTrack(ctx *Context, args []string) error {
+    if err := onDevice(ctx, func(deviceId string) error { return ctx.Client.Previous(ctx, deviceId) }); err != nil {
+        return err
+    }
+
+    return ctx.Report(actionResult{Action: "previous", Message: "Playing previous"})
+}
+
+func restartTrack(ctx *Context, args []string) error {
+    if err := onDevice(ctx, func(deviceId string) error { return ctx.Client.Seek(ctx, deviceId, 0) }); err != nil {
+        return err
+    }
+
+    return ctx.Report(actionResult{Action: "restart", Message: "Playing from the beginning"})
+}
+
+type seekResult struct {
+    Action     string `json:"action"`
+    PositionMs int    `json:"position_ms"`
+}
+
+func seekCommand() *Command {
+    return &Command{
+        Name: "seek",
+        Description: "Move to the position in current song\n" +
+            "Position is absolute (1:32, 92s, 92) or relative to the current one (+15s, -10s, +1:00).",
+        Args: []Arg{
+            {Name: "position", Description: "where to move, e.g. 1:32, 92s, +15s or -10s"},
+        },
+        Run: func(ctx *Context, args []string) error {
+            position, relative, err := parsePosition(args[0])
+            if err != nil {
+                return usageErrorf(ctx.Command, "%s", err.Error())
+            }
+            positionMs := int(position / time.Millisecond)
+
+            if relative {
+                state, err := currentPlayback(ctx)
+                if err != nil {
+                    return err
+                }
+                if state.Item == nil {
+                    return errors.New("nothing to seek in")
+                }
+                positionMs += state.ProgressMs
+                if positionMs > state.Item.DurationMs {
+                    positionMs = state.Item.DurationMs
+                }
+            }
+            if positionMs < 0 {
+                positionMs = 0
+            }
+
+            err = onDevice(ctx, func(deviceId string) error { return ctx.Client.Seek(ctx, deviceId, positionMs) })
+            if err != nil {
+                return err
+            }
+
+            result := seekResult{Action: "seek", PositionMs: positionMs}
+            return ctx.Render(result, func(w io.Writer) {
+                _, _ = fmt.Fprintln(w, "Moved to "+formatDuration(positionMs))
+            })
+        },
+    }
+}
+
+// parsePosition understands "1:32", "1:02:03", "92s", "1m32s" and plain seconds, with
+// optional sign making the position relative
+func parsePosition(s string) (position time.Duration, relative bool, err error) {
+    sign := time.Duration(1)
+    if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+        relative = true
+        if s[0] == '-' {
+            sign = -1
+        }
+        s = s[1:]
+    }
+
+    malformed := errors.New("malformed position \"" + s + "\", use e.g. 1:32, 92s or +15s")
+    switch {
+    case strings.Contains(s, ":"):
+        parts := strings.Split(s, ":")
+        if len(parts) > 3 {
+            return 0, false, malformed
+        }
+        for _, p := range parts {
+            n, err := strconv.Atoi(p)
+            if err != nil || n < 0 {
+                return 0, false, malformed
+            }
+            position = position*60 + time.Duration(n)*time.Second
+        }
+    default:
+        if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+            position = time.Duration(n) * time.Second
+        } else if position, err = time.ParseDuration(s); err != nil || position < 0 {
+            return 0, false, malformed
+        }
+    }
+    return sign * position, relative, nil
+}
+
+// onDevice runs player command on the active device. If there is none, the command is
+// repeated on the first available device.
+func onDevice(ctx *Context, fn func(deviceId string) error) error {
+    err := fn("")
+    if !errors.Is(err, spotify.ErrNoActiveDevice) {
+        return err
+    }
+    deviceId, err := fallbackDevice(ctx)
+    if err != nil {
+        return err
+    }
+    return fn(deviceId)
+}
+
+// onChosenDevice runs player command on the device user asked for, or like onDevice when
+// they did not. The chosen device is never swapped for another one: if Spotify does not
+// take commands for it, playback is moved there first.
+func onChosenDevice(ctx *Context, deviceId string, fn func(deviceId string) error) error {
+    if deviceId == "" {
+        return onDevice(ctx, fn)
+    }
+    err := fn(deviceId)
+    if !errors.Is(err, spotify.ErrNoActiveDevice) {
+        return err
+    }
+    if err := ctx.Client.TransferPlayback(ctx, deviceId, false); err != nil {
+        return err
+    }
+    return fn(deviceId)
+}
+
+// fallbackDevice picks the device to use when nothing is active
+func fallbackDevice(ctx *Context) (string, error) {
+    devices, err := ctx.Client.Devices(ctx)
+    if err != nil {
+        return "", err
+    }
+    if len(devices) == 0 {
+        return "", ErrNoDevices
+    }
+    return preferredDevice(ctx, devices).Id, nil
+}
+
+// playingItem is the track or episode playing right now
+func playingItem(ctx *Context) (*spotify.Track, error) {
+    state, err := ctx.Client.PlaybackState(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if state == nil || state.Item == nil {
+        return nil, errors.New("nothing is playing right now")
+    }
+    return state.Item, nil
+}
+
+func playRandomSong(ctx *Context, args []string) error {
+    rand.Seed(time.Now().UnixNano())
+
+    c, err := ctx.Client.Categories(ctx, 50, 0)
+    if err != nil {
+        return err
+    }
+    if len(c.Items) == 0 {
+        return errors.New("no categories available")
+    }
+    category := c.Items[rand.Intn(len(c.Items))]
+
+    p, err := ctx.Client.CategoryPlaylists(ctx, category.Id, 50, 0)
+    if err != nil {
+        return err
+    }
+    if len(p.Items) == 0 {
+        return errors.New("no playlists in category " + category.Name)
+    }
+    playlist := p.Items[rand.Intn(len(p.Items))]
+
+    if err := playUri(ctx, "spotify:playlist:"+playlist.Id); err != nil {
+        return err
+    }
+
+    return ctx.Report(actionResult{
+        Action:  "play",
+        Uri:     "spotify:playlist:" + playlist.Id,
+        Name:    playlist.Name,
+        Message: "Playing for you now: [" + category.Name + "] " + playlist.Name + " - " + playlist.Description,
+    })
+}
+
+func playCommand() *Command {
+    var kind, offset, position string
+    return &Command{
+        Name: "play",
+        Description: "Play Spotify URI, share link or the best search match, resume without argument\n" +
+            "Tracks and episodes are played by themselves; albums, playlists, artists and shows\n" +
+            "as a context, where --offset picks the item to start from.",
+        Args: []Arg{
+            {Name: "uri|url|query", Description: "e.g. spotify:track:..., https://open.spotify.com/... or free text", Optional: true, Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&kind, "type", "track", "what to search for when given a query, one of "+strings.Join(spotify.SearchTypes, ", "))
+            fs.StringVar(&offset, "offset", "", "`number` (from 0) or URI of the item in context to start from")
+            fs.StringVar(&position, "position", "", "where to start in the item, e.g. `1:32` or 92s")
+        },
+        Run: func(ctx *Context, args []string) error {
+            opts := spotify.PlayOptions{}
+            uri, name := "", ""
+            if len(args) > 0 {
+                var err error
+                uri, err = spotify.ParseUri(strings.Join(args, " "))
+                if err == spotify.ErrNotUri {
+                    item, err := searchBest(ctx, strings.Join(args, " "), kind)
+                    if err != nil {
+                        return err
+                    }
+                    uri, name = item.Uri, item.Type+" "+item.Name
+                }
+                opts = uriPlayOptions(uri)
+                if name == "" {
+                    name = uri
+                }
+            }
+
+            if offset != "" {
+                if n, err := strconv.Atoi(offset); err == nil && n >= 0 {
+                    opts.OffsetPosition = n
+                } else if uri, err := spotify.ParseUri(offset); err == nil {
+                    opts.OffsetUri = uri
+                } else {
+                    return usageErrorf(ctx.Command, "malformed offset %q, use number or URI", offset)
+                }
+            }
+            if position != "" {
+                d, relative, err := parsePosition(position)
+                if err != nil || relative {
+                    return usageErrorf(ctx.Command, "malformed position %q, use e.g. 1:32 or 92s", position)
+                }
+                opts.PositionMs = int(d / time.Millisecond)
+            }
+            if opts.ContextUri == "" && len(opts.Uris) == 0 && (offset != "" || position != "") {
+                return usageErrorf(ctx.Command, "--offset and --position need something to play")
+            }
+
+            if err := startPlayback(ctx, opts); err != nil {
+                return err
+            }
+
+            if name == "" {
+                return ctx.Report(actionResult{Action: "resume", Message: "Resumed playback"})
+            }
+            return ctx.Report(actionResult{Action: "play", Uri: uri, Name: name, Message: "Playing " + name})
+        },
+    }
+}
+
+// searchBest resolves free text query to the best match of the given type
+func searchBest(ctx *Context, query string, kind string) (*searchItem, error) {
+    types, err := parseSearchTypes(kind)
+    if err != nil {
+        return nil, usageErrorf(ctx.Command, "%s", err.Error())
+    }
+    result, err := ctx.Client.Search(ctx, query, types, 1, 0)
+    if err != nil {
+        return nil, err
+    }
+    for _, item := range searchItems(result) {
+        if item.Type == types[0] {
+            return &item, nil
+        }
+    }
+    return nil, errors.New("nothing found for \"" + query + "\"")
+}
+
+// uriPlayOptions plays tracks and episodes by themselves and anything else as
+// a context to play from
+func uriPlayOptions(uri string) spotify.PlayOptions {
+    if kind, _ := spotify.SplitUri(uri); kind == "track" || kind == "episode" {
+        return spotify.PlayOptions{Uris: []string{uri}}
+    }
+    return spotify.PlayOptions{ContextUri: uri}
+}
+
+// playUri plays any Spotify URI on the active device or the fallback one
+func playUri(ctx *Context, uri string) error {
+    return startPlayback(ctx, uriPlayOptions(uri))
+}
+
+func startPlayback(ctx *Context, opts spotify.PlayOptions) error {
+    return onDevice(ctx, func(deviceId string) error {
+        opts.DeviceId = deviceId
+        return ctx.Client.Play(ctx, opts)
+    })
+}
@@ -0,0 +1,697 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+const playlistReadScope = "playlist-read-private"
+
+// playlistFields is what we need to know about playlist to work with it, items are
+// listed page by page
+const playlistFields = "id,uri,name,description,owner(id,display_name),public,collaborative,snapshot_id,tracks(total)"
+
+var playlistIdPattern = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+func playlistCommand() *Command {
+    return &Command{
+        Name: "playlist",
+        Description: "Manage your playlists\n" +
+            "Playlist is given by URI, link, id or name of one of your playlists; names are matched\n" +
+            "like device names, see `spotify device --help`. Edits take --snapshot, the snapshot id\n" +
+            "printed by `playlist show`, to fail instead of changing a playlist someone else has\n" +
+            "edited in the meantime. Edits by item number do that check against the last\n" +
+            "`playlist show` even without --snapshot, others only when it is given.",
+        Subcommands: []*Command{
+            playlistListCommand(),
+            playlistShowCommand(),
+            playlistCreateCommand(),
+            playlistAddCommand(),
+            playlistRemoveCommand(),
+            playlistDetailsCommand("rename", "Change name of the playlist", "name"),
+            playlistDetailsCommand("describe", "Change description of the playlist", "description"),
+            playlistExportCommand(),
+            playlistImportCommand(),
+        },
+    }
+}
+
+var playlistArg = Arg{Name: "playlist", Description: "URI, link, id or name of the playlist"}
+
+func playlistListCommand() *Command {
+    var limit, offset int
+    return &Command{
+        Name:        "list",
+        Aliases:     []string{"ls"},
+        Description: "List playlists you own or follow",
+        Flags: func(fs *flag.FlagSet) {
+            fs.IntVar(&limit, "limit", 20, "number of playlists to list, 1-50")
+            fs.IntVar(&offset, "offset", 0, "number of playlists to skip")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if limit < 1 || limit > 50 {
+                return usageErrorf(ctx.Command, "limit must be within 1-50")
+            }
+            if offset < 0 {
+                return usageErrorf(ctx.Command, "offset cannot be negative")
+            }
+            if err := requireScopes(ctx, playlistReadScope); err != nil {
+                return err
+            }
+            page, err := ctx.Client.CurrentUserPlaylists(ctx, limit, offset)
+            if err != nil {
+                return err
+            }
+
+            rows := make([]playlistRow, 0, len(page.Items))
+            for i := range page.Items {
+                rows = append(rows, newPlaylistRow(offset+i, &page.Items[i]))
+            }
+            err = ctx.Render(rows, func(w io.Writer) {
+                if len(rows) == 0 {
+                    _, _ = fmt.Fprintln(w, "No playlists here")
+                    return
+                }
+                for _, row := range rows {
+                    _, _ = fmt.Fprintln(w, "["+strconv.Itoa(row.Position)+"] "+row.Name+" ("+describePlaylist(row)+")")
+                }
+            })
+            if err == nil && page.Next != "" && !ctx.machineOutput() {
+                printMoreHint(ctx, offset, len(rows), page.Total)
+            }
+            return err
+        },
+    }
+}
+
+type playlistRow struct {
+    Position      int    `json:"position"`
+    Id            string `json:"id"`
+    Uri           string `json:"uri"`
+    Name          string `json:"name"`
+    Owner         string `json:"owner"`
+    Tracks        int    `json:"tracks"`
+    Public        bool   `json:"public"`
+    Collaborative bool   `json:"collaborative"`
+}
+
+func newPlaylistRow(position int, p *spotify.Playlist) playlistRow {
+    row := playlistRow{
+        Position:      position,
+        Id:            p.Id,
+        Uri:           p.Uri,
+        Name:          p.Name,
+        Owner:         p.Owner.DisplayName,
+        Public:        p.Public,
+        Collaborative: p.Collaborative,
+    }
+    if row.Owner == "" {
+        row.Owner = p.Owner.Id
+    }
+    if p.Tracks != nil {
+        row.Tracks = p.Tracks.Total
+    }
+    return row
+}
+
+// describePlaylist formats playlist as "by Alice, 25 tracks, collaborative"
+func describePlaylist(row playlistRow) string {
+    visibility := "private"
+    if row.Collaborative {
+        visibility = "collaborative"
+    } else if row.Public {
+        visibility = "public"
+    }
+    return "by " + row.Owner + ", " + strconv.Itoa(row.Tracks) + " tracks, " + visibility
+}
+
+func playlistShowCommand() *Command {
+    var limit, offset int
+    return &Command{
+        Name:        "show",
+        Description: "Show details and items of a playlist",
+        Args:        []Arg{playlistArg},
+        Flags: func(fs *flag.FlagSet) {
+            fs.IntVar(&limit, "limit", 100, "number of items to list, 1-100")
+            fs.IntVar(&offset, "offset", 0, "number of items to skip")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if limit < 1 || limit > 100 {
+                return usageErrorf(ctx.Command, "limit must be within 1-100")
+            }
+            if offset < 0 {
+                return usageErrorf(ctx.Command, "offset cannot be negative")
+            }
+            playlist, err := resolvePlaylist(ctx, args[0])
+            if err != nil {
+                return err
+            }
+            page, err := ctx.Client.PlaylistItems(ctx, playlist.Id, limit, offset)
+            if err != nil {
+                return err
+            }
+            err = updateLocalState(ctx, func(s *LocalState) error {
+                s.PlaylistSnapshots[playlist.Id] = playlist.SnapshotId
+                return nil
+            })
+            if err != nil {
+                return err
+            }
+
+            row := newPlaylistRow(0, playlist)
+            result := playlistResult{
+                Id:            row.Id,
+                Uri:           row.Uri,
+                Name:          row.Name,
+                Description:   playlist.Description,
+                Owner:         row.Owner,
+                Tracks:        row.Tracks,
+                Public:        row.Public,
+                Collaborative: row.Collaborative,
+                SnapshotId:    playlist.SnapshotId,
+                Items:         make([]playlistItemRow, 0, len(page.Items)),
+            }
+            for i, item := range page.Items {
+                result.Items = append(result.Items, newPlaylistItemRow(offset+i, item))
+            }
+            err = ctx.Render(result, func(w io.Writer) {
+                _, _ = fmt.Fprintln(w, result.Name)
+                if result.Description != "" {
+                    _, _ = fmt.Fprintln(w, "  "+result.Description)
+                }
+                _, _ = fmt.Fprintln(w, "  "+describePlaylist(row)+", snapshot "+result.SnapshotId)
+                for i, item := range page.Items {
+                    line := "(unavailable)"
+                    if item.Track != nil {
+                        line = describeItem(item.Track)
+                    }
+                    _, _ = fmt.Fprintln(w, "["+strconv.Itoa(result.Items[i].Position)+"] "+line)
+                }
+            })
+            if err == nil && page.Next != "" && !ctx.machineOutput() {
+                printMoreHint(ctx, offset, len(page.Items), page.Total)
+            }
+            return err
+        },
+    }
+}
+
+type playlistResult struct {
+    Id            string            `json:"id"`
+    Uri           string            `json:"uri"`
+    Name          string            `json:"name"`
+    Description   string            `json:"description"`
+    Owner         string            `json:"owner"`
+    Tracks        int               `json:"tracks"`
+    Public        bool              `json:"public"`
+    Collaborative bool              `json:"collaborative"`
+    SnapshotId    string            `json:"snapshot_id"`
+    Items         []playlistItemRow `json:"items"`
+}
+
+func (r playlistResult) Rows() interface{} {
+    return r.Items
+}
+
+type playlistItemRow struct {
+    // Position is the number to remove the item by, counting from 0
+    Position int    `json:"position"`
+    AddedAt  string `json:"added_at"`
+    AddedBy  string `json:"added_by"`
+    *itemResult
+}
+
+func newPlaylistItemRow(position int, item spotify.PlaylistItem) playlistItemRow {
+    row := playlistItemRow{Position: position, AddedAt: item.AddedAt}
+    if item.AddedBy != nil {
+        row.AddedBy = item.AddedBy.Id
+    }
+    if item.Track != nil {
+        row.itemResult = newItemResult(item.Track)
+    } else {
+        row.itemResult = &itemResult{Artists: []string{}}
+    }
+    return row
+}
+
+func playlistCreateCommand() *Command {
+    var public, collaborative bool
+    var description string
+    return &Command{
+        Name:        "create",
+        Description: "Create a playlist, private unless --public is given",
+        Args: []Arg{
+            {Name: "name", Description: "name of the new playlist", Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.BoolVar(&public, "public", false, "show the playlist on your profile")
+            fs.BoolVar(&collaborative, "collaborative", false, "let people you share it with edit it")
+            fs.StringVar(&description, "description", "", "`text` describing the playlist")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if public && collaborative {
+                return usageErrorf(ctx.Command, "collaborative playlists cannot be public")
+            }
+            scope := "playlist-modify-private"
+            if public {
+                scope = "playlist-modify-public"
+            }
+            if err := requireScopes(ctx, scope); err != nil {
+                return err
+            }
+            userId, err := currentUserId(ctx)
+            if err != nil {
+                return err
+            }
+
+            details := spotify.PlaylistDetails{Name: strings.Join(args, " "), Public: &public}
+            if collaborative {
+                details.Collaborative = &collaborative
+            }
+            if description != "" {
+                details.Description = &description
+            }
+            playlist, err := ctx.Client.CreatePlaylist(ctx, userId, details)
+            if err != nil {
+                return err
+            }
+
+            return renderPlaylistEdit(ctx, playlistEditResult{
+                Action:     "create",
+                Uri:        playlist.Uri,
+                Name:       playlist.Name,
+                SnapshotId: playlist.SnapshotId,
+                Message:    "Created playlist " + playlist.Name + " (" + playlist.Uri + ")",
+            })
+        },
+    }
+}
+
+// currentUserId is the id of the account we are logged in to, which credentials
+// usually know already
+func currentUserId(ctx *Context) (string, error) {
+    if c, err := ctx.Store.Load(); err == nil && c.AccountId != "" {
+        return c.AccountId, nil
+    }
+    user, err := ctx.Client.CurrentUser(ctx)
+    if err != nil {
+        return "", err
+    }
+    return user.Id, nil
+}
+
+func playlistAddCommand() *Command {
+    var snapshot string
+    var position int
+    return &Command{
+        Name: "add",
+        Description: "Add tracks or episodes to a playlist\n" +
+            "Items are given by URI or link, \"current\" is the one playing right now. They are\n" +
+            "added in order to the end of the playlist, or from --position. Appending does not check\n" +
+            "the snapshot unless --snapshot is given; --position is a number from `playlist show`\n" +
+            "and fails when the playlist changed since.",
+        Args: []Arg{
+            playlistArg,
+            {Name: "uri|url|current", Description: "what to add", Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.IntVar(&position, "position", -1, "insert items from this `number` (from 0) instead of appending")
+            fs.StringVar(&snapshot, "snapshot", "", "fail if the playlist is no longer at this snapshot `id`")
+        },
+        Run: func(ctx *Context, args []string) error {
+            items := make([]spotify.Track, 0, len(args)-1)
+            for _, arg := range args[1:] {
+                item, err := resolvePlaylistItem(ctx, arg)
+                if err != nil {
+                    return err
+                }
+                items = append(items, *item)
+            }
+            playlist, err := editablePlaylist(ctx, args[0], snapshot)
+            if err != nil {
+                return err
+            }
+            if position >= 0 && snapshot == "" {
+                if err := checkShownSnapshot(ctx, playlist); err != nil {
+                    return err
+                }
+            }
+
+            result := playlistEditResult{Action: "add", Uri: playlist.Uri, Name: playlist.Name, Items: len(items)}
+            for start := 0; start < len(items); start += spotify.MaxPlaylistItems {
+                end := start + spotify.MaxPlaylistItems
+                if end > len(items) {
+                    end = len(items)
+                }
+                uris := make([]string, 0, end-start)
+                for _, item := range items[start:end] {
+                    uris = append(uris, item.Uri)
+                }
+                at := -1
+                if position >= 0 {
+                    at = position + start
+                }
+                if result.SnapshotId, err = ctx.Client.AddPlaylistItems(ctx, playlist.Id, uris, at); err != nil {
+                    return err
+                }
+            }
+
+            result.Message = "Added " + countItems(items) + " to " + playlist.Name
+            return renderPlaylistEdit(ctx, result)
+        },
+    }
+}
+
+// resolvePlaylistItem parses URI or link of a track or episode, "current" is the one
+// playing. Names of parsed items are their URIs.
+func resolvePlaylistItem(ctx *Context, arg string) (*spotify.Track, error) {
+    if arg == "current" {
+        return playingItem(ctx)
+    }
+    uri, err := spotify.ParseUri(arg)
+    kind, id := spotify.SplitUri(uri)
+    if err != nil || (kind != "track" && kind != "episode") {
+        return nil, usageErrorf(ctx.Command, "%q is not a track or episode URI or link", arg)
+    }
+    return &spotify.Track{Id: id, Uri: uri, Type: kind, Name: uri}, nil
+}
+
+// countItems is "Song" for a single item and "3 items" otherwise
+func countItems(items []spotify.Track) string {
+    if len(items) == 1 {
+        return items[0].Name
+    }
+    return strconv.Itoa(len(items)) + " items"
+}
+
+func playlistRemoveCommand() *Command {
+    var snapshot string
+    return &Command{
+        Name:    "remove",
+        Aliases: []string{"rm"},
+        Description: "Remove items from a playlist\n" +
+            "Items are given by their number in `playlist show`, which removes just that entry, or\n" +
+            "by URI, link or \"current\", which removes all entries of the item. Numbers fail to\n" +
+            "remove anything when the playlist changed since `playlist show` printed them.",
+        Args: []Arg{
+            playlistArg,
+            {Name: "number|uri|url|current", Description: "what to remove", Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&snapshot, "snapshot", "", "fail if the playlist is no longer at this snapshot `id`")
+        },
+        Run: func(ctx *Context, args []string) error {
+            var positions []int
+            var uris []string
+            for _, arg := range args[1:] {
+                if n, err := strconv.Atoi(arg); err == nil {
+                    if n < 0 {
+                        return usageErrorf(ctx.Command, "there is no item number %d", n)
+                    }
+                    positions = append(positions, n)
+                    continue
+                }
+                item, err := resolvePlaylistItem(ctx, arg)
+                if err != nil {
+                    return err
+                }
+                uris = append(uris, item.Uri)
+            }
+            playlist, err := editablePlaylist(ctx, args[0], snapshot)
+            if err != nil {
+                return err
+            }
+            if len(positions) > 0 && snapshot == "" {
+                if err := checkShownSnapshot(ctx, playlist); err != nil {
+                    return err
+                }
+            }
+
+            refs, err := playlistItemRefs(ctx, playlist, positions, uris)
+            if err != nil {
+                return err
+            }
+            // positions are taken as they were in this snapshot, so entries removed by
+            // earlier chunks do not shift them
+            snapshotId := playlist.SnapshotId
+            result := playlistEditResult{Action: "remove", Uri: playlist.Uri, Name: playlist.Name}
+            for start := 0; start < len(refs); start += spotify.MaxPlaylistItems {
+                end := start + spotify.MaxPlaylistItems
+                if end > len(refs) {
+                    end = len(refs)
+                }
+                if result.SnapshotId, err = ctx.Client.RemovePlaylistItems(ctx, playlist.Id, refs[start:end], snapshotId); err != nil {
+                    return err
+                }
+            }
+
+            // a URI removes all of its entries, however many there are, so the count is
+            // what the playlist lost; the removal is done already, so a failure to count
+            // only leaves the count out
+            result.Message = "Removed given items from " + playlist.Name
+            if after, err := ctx.Client.Playlist(ctx, playlist.Id, "tracks(total)"); err == nil && after.Tracks != nil && playlist.Tracks != nil {
+                result.Items = playlist.Tracks.Total - after.Tracks.Total
+                result.Message = "Removed " + strconv.Itoa(result.Items) + " items from " + playlist.Name
+                if result.Items == 1 {
+                    result.Message = "Removed 1 item from " + playlist.Name
+                }
+            }
+            return renderPlaylistEdit(ctx, result)
+        },
+    }
+}
+
+// playlistItemRefs finds out URIs of items at positions, which API needs to remove
+// them, and adds URIs to remove all entries of
+func playlistItemRefs(ctx *Context, playlist *spotify.Playlist, positions []int, uris []string) ([]spotify.PlaylistItemRef, error) {
+    total := 0
+    if playlist.Tracks != nil {
+        total = playlist.Tracks.Total
+    }
+    pages := map[int]*spotify.PlaylistItemPage{}
+    byUri := map[string][]int{}
+    var order []string
+    for _, n := range positions {
+        if n >= total {
+            return nil, usageErrorf(ctx.Command, "there is no item number %d, the playlist has %d", n, total)
+        }
+        offset := n / spotify.MaxPlaylistItems * spotify.MaxPlaylistItems
+        if pages[offset] == nil {
+            page, err := ctx.Client.PlaylistItems(ctx, playlist.Id, spotify.MaxPlaylistItems, offset)
+            if err != nil {
+                return nil, err
+            }
+            pages[offset] = page
+        }
+        page := pages[offset]
+        if n-offset >= len(page.Items) || page.Items[n-offset].Track == nil {
+            return nil, errors.New("item number " + strconv.Itoa(n) + " is not available and cannot be removed by number")
+        }
+        uri := page.Items[n-offset].Track.Uri
+        if _, ok := byUri[uri]; !ok {
+            order = append(order, uri)
+        }
+        if !containsInt(byUri[uri], n) {
+            byUri[uri] = append(byUri[uri], n)
+        }
+    }
+
+    refs := make([]spotify.PlaylistItemRef, 0, len(order)+len(uris))
+    for _, uri := range order {
+        sort.Ints(byUri[uri])
+        refs = append(refs, spotify.PlaylistItemRef{Uri: uri, Positions: byUri[uri]})
+    }
+    for _, uri := range uris {
+        refs = append(refs, spotify.PlaylistItemRef{Uri: uri})
+    }
+    return refs, nil
+}
+
+func containsInt(values []int, v int) bool {
+    for _, value := range values {
+        if value == v {
+            return true
+        }
+    }
+    return false
+}
+
+func playlistDetailsCommand(name string, description string, field string) *Command {
+    var snapshot string
+    return &Command{
+        Name:        name,
+        Description: description,
+        Args: []Arg{
+            playlistArg,
+            {Name: field, Description: "new " + field + " of the playlist", Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&snapshot, "snapshot", "", "fail if the playlist is no longer at this snapshot `id`")
+        },
+        Run: func(ctx *Context, args []string) error {
+            value := strings.Join(args[1:], " ")
+            playlist, err := editablePlaylist(ctx, args[0], snapshot)
+            if err != nil {
+                return err
+            }
+
+            var details spotify.PlaylistDetails
+            result := playlistEditResult{Action: name, Uri: playlist.Uri, Name: playlist.Name}
+            if field == "name" {
+                details.Name = value
+                result.Name = value
+                result.Message = "Renamed " + playlist.Name + " to " + value
+            } else {
+                details.Description = &value
+                result.Message = "Changed description of " + playlist.Name
+            }
+            if err := ctx.Client.ChangePlaylistDetails(ctx, playlist.Id, details); err != nil {
+                return err
+            }
+            return renderPlaylistEdit(ctx, result)
+        },
+    }
+}
+
+// playlistEditResult is the result of commands changing playlist, SnapshotId is the
+// snapshot after the change if API tells it
+type playlistEditResult struct {
+    Action     string `json:"action"`
+    Uri        string `json:"uri"`
+    Name       string `json:"name"`
+    Items      int    `json:"items,omitempty"`
+    SnapshotId string `json:"snapshot_id,omitempty"`
+    Message    string `json:"message"`
+}
+
+func renderPlaylistEdit(ctx *Context, result playlistEditResult) error {
+    return ctx.Render(result, func(w io.Writer) {
+        _, _ = fmt.Fprintln(w, result.Message)
+    })
+}
+
+// editablePlaylist resolves playlist to change, checking that it is ours or collaborative,
+// that the login has scope to change it and that nobody else did since the expected
+// snapshot, if one is given
+func editablePlaylist(ctx *Context, s string, snapshot string) (*spotify.Playlist, error) {
+    playlist, err := resolvePlaylist(ctx, s)
+    if err != nil {
+        return nil, err
+    }
+    if !playlist.Collaborative {
+        userId, err := currentUserId(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if playlist.Owner.Id != userId {
+            return nil, errors.New("playlist " + playlist.Name + " belongs to " + describeUser(&playlist.Owner) +
+                " and is not collaborative, only its owner can change it")
+        }
+    }
+    scope := "playlist-modify-private"
+    if playlist.Public {
+        scope = "playlist-modify-public"
+    }
+    if err := requireScopes(ctx, scope); err != nil {
+        return nil, err
+    }
+    if snapshot != "" && playlist.SnapshotId != snapshot {
+        return nil, &ConflictError{Playlist: playlist.Name, Expected: snapshot, Actual: playlist.SnapshotId}
+    }
+    return playlist, nil
+}
+
+// checkShownSnapshot makes sure item numbers still mean what they did when `playlist show`
+// printed them, for edits by number without --snapshot
+func checkShownSnapshot(ctx *Context, playlist *spotify.Playlist) error {
+    shown := ""
+    err := updateLocalState(ctx, func(s *LocalState) error {
+        shown = s.PlaylistSnapshots[playlist.Id]
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    if shown == "" {
+        return errors.New("item numbers are those of `spotify playlist show`, run it first or give --snapshot")
+    }
+    if shown != playlist.SnapshotId {
+        return &ConflictError{Playlist: playlist.Name, Expected: shown, Actual: playlist.SnapshotId}
+    }
+    return nil
+}
+
+// resolvePlaylist finds playlist by URI, link, id or name of one of user's playlists
+func resolvePlaylist(ctx *Context, s string) (*spotify.Playlist, error) {
+    id := ""
+    if uri, err := spotify.ParseUri(s); err == nil {
+        kind, uriId := spotify.SplitUri(uri)
+        if kind != "playlist" {
+            return nil, usageErrorf(ctx.Command, "%q is not a playlist", s)
+        }
+        id = uriId
+    } else if playlistIdPattern.MatchString(s) {
+        id = s
+    }
+    if id != "" {
+        playlist, err := ctx.Client.Playlist(ctx, id, playlistFields)
+        if errors.Is(err, spotify.ErrNotFound) {
+            return nil, errors.New("no playlist \"" + s + "\"")
+        }
+        return playlist, err
+    }
+
+    if err := requireScopes(ctx, playlistReadScope); err != nil {
+        return nil, err
+    }
+    var playlists []spotify.Playlist
+    for offset := 0; ; offset += 50 {
+        page, err := ctx.Client.CurrentUserPlaylists(ctx, 50, offset)
+        if err != nil {
+            return nil, err
+        }
+        playlists = append(playlists, page.Items...)
+        if page.Next == "" || len(page.Items) == 0 {
+            break
+        }
+    }
+    return matchPlaylist(playlists, s)
+}
+
+// matchPlaylist finds playlist by name ignoring case, exact match goes first, then
+// prefix, then any part of the name; within the best level there must be only one match.
+func matchPlaylist(playlists []spotify.Playlist, s string) (*spotify.Playlist, error) {
+    needle := strings.ToLower(s)
+    levels := []func(name string) bool{
+        func(name string) bool { return name == needle },
+        func(name string) bool { return strings.HasPrefix(name, needle) },
+        func(name string) bool { return strings.Contains(name, needle) },
+    }
+    for _, matches := range levels {
+        var found []*spotify.Playlist
+        for i := range playlists {
+            if matches(strings.ToLower(playlists[i].Name)) {
+                found = append(found, &playlists[i])
+            }
+        }
+        if len(found) == 1 {
+            return found[0], nil
+        }
+        if len(found) > 1 {
+            names := make([]string, 0, len(found))
+            for _, p := range found {
+                names = append(names, "\""+p.Name+"\" ("+p.Id+")")
+            }
+            return nil, errors.New("\"" + s + "\" matches several playlists: " + strings.Join(names, ", "))
+        }
+    }
+    return nil, errors.New("no playlist \"" + s + "\" among yours, see `spotify playlist list`")
+}
@@ -0,0 +1,653 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/sha256"
+    "encoding/csv"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+// Formats of playlist files
+const (
+    PlaylistJson = "json"
+    PlaylistCsv  = "csv"
+    PlaylistM3u  = "m3u"
+)
+
+var playlistFileFormats = []string{PlaylistJson, PlaylistCsv, PlaylistM3u}
+
+// csvColumns are columns of exported CSV, import also takes files with some of them
+// only, in any order
+var csvColumns = []string{"uri", "name", "artists", "album", "isrc", "duration_ms"}
+
+// exportedPlaylist is what playlist files hold, in JSON as is
+type exportedPlaylist struct {
+    Name        string          `json:"name"`
+    Description string          `json:"description,omitempty"`
+    Uri         string          `json:"uri,omitempty"`
+    Tracks      []exportedTrack `json:"tracks"`
+}
+
+// exportedTrack is an item of playlist file. Items without URI, e.g. from other
+// services, are found by ISRC or by artist and name on import.
+type exportedTrack struct {
+    Uri        string   `json:"uri,omitempty"`
+    Name       string   `json:"name"`
+    Artists    []string `json:"artists"`
+    Album      string   `json:"album,omitempty"`
+    Isrc       string   `json:"isrc,omitempty"`
+    DurationMs int      `json:"duration_ms,omitempty"`
+}
+
+func playlistExportCommand() *Command {
+    var format, file string
+    return &Command{
+        Name: "export",
+        Description: "Write playlist items to a file\n" +
+            "Every item is written with its URI, name, artists, album, ISRC and duration, so it can be\n" +
+            "imported back or found on other services. Format is taken from the file extension unless\n" +
+            "--format is given, JSON is the default.",
+        Args: []Arg{playlistArg},
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&format, "format", "", "file `format`: "+strings.Join(playlistFileFormats, ", "))
+            fs.StringVar(&file, "file", "", "write to the `path` instead of stdout")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if format == "" {
+                format = formatOfPath(file)
+            }
+            if err := checkPlaylistFileFormat(format); err != nil {
+                return usageErrorf(ctx.Command, "%s", err.Error())
+            }
+            playlist, err := resolvePlaylist(ctx, args[0])
+            if err != nil {
+                return err
+            }
+
+            exported := exportedPlaylist{Name: playlist.Name, Description: playlist.Description, Uri: playlist.Uri}
+            for offset := 0; ; offset += spotify.MaxPlaylistItems {
+                page, err := ctx.Client.PlaylistItems(ctx, playlist.Id, spotify.MaxPlaylistItems, offset)
+                if err != nil {
+                    return err
+                }
+                for _, item := range page.Items {
+                    if item.Track != nil {
+                        exported.Tracks = append(exported.Tracks, newExportedTrack(item.Track))
+                    }
+                }
+                if page.Next == "" || len(page.Items) == 0 {
+                    break
+                }
+            }
+
+            var content bytes.Buffer
+            if err := writePlaylistFile(&content, format, &exported); err != nil {
+                return err
+            }
+            if file == "" {
+                _, err = ctx.Stdout.Write(content.Bytes())
+                return err
+            }
+            if err := ioutil.WriteFile(file, content.Bytes(), 0644); err != nil {
+                return err
+            }
+            return ctx.Report(actionResult{
+                Action:  "export",
+                Uri:     playlist.Uri,
+                Name:    playlist.Name,
+                Message: "Exported " + strconv.Itoa(len(exported.Tracks)) + " items of " + playlist.Name + " to " + file,
+            })
+        },
+    }
+}
+
+func newExportedTrack(item *spotify.Track) exportedTrack {
+    result := newItemResult(item)
+    t := exportedTrack{
+        Uri:        result.Uri,
+        Name:       result.Name,
+        Artists:    result.Artists,
+        Album:      result.Album,
+        DurationMs: result.DurationMs,
+    }
+    if item.ExternalIds != nil {
+        t.Isrc = item.ExternalIds.Isrc
+    }
+    return t
+}
+
+func playlistImportCommand() *Command {
+    var format, name string
+    var public, collaborative, restart bool
+    return &Command{
+        Name: "import",
+        Description: "Create a playlist from a file\n" +
+            "Takes files written by `playlist export`; items without URI are found by ISRC, or by\n" +
+            "artist and name, and skipped with a warning if there is no match. Items are added 100\n" +
+            "at a time. If import stops half way, running the same command again resumes it, unless\n" +
+            "--restart is given; another file path, profile, --name or visibility starts a new import.",
+        Args: []Arg{
+            {Name: "file", Description: "playlist file, \"-\" for stdin"},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&format, "format", "", "file `format`: "+strings.Join(playlistFileFormats, ", ")+", guessed by default")
+            fs.StringVar(&name, "name", "", "`name` of the new playlist instead of the one in the file")
+            fs.BoolVar(&public, "public", false, "show the playlist on your profile")
+            fs.BoolVar(&collaborative, "collaborative", false, "let people you share it with edit it")
+            fs.BoolVar(&restart, "restart", false, "start over instead of resuming unfinished import of the file")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if public && collaborative {
+                return usageErrorf(ctx.Command, "collaborative playlists cannot be public")
+            }
+            content, err := readInput(ctx, args[0])
+            if err != nil {
+                return err
+            }
+            if format == "" {
+                format = detectPlaylistFormat(args[0], content)
+            }
+            if err := checkPlaylistFileFormat(format); err != nil {
+                return usageErrorf(ctx.Command, "%s", err.Error())
+            }
+            imported, err := readPlaylistFile(content, format)
+            if err != nil {
+                return errors.New("cannot read " + args[0] + ": " + err.Error())
+            }
+            if name != "" {
+                imported.Name = name
+            }
+            if imported.Name == "" {
+                imported.Name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+            }
+            scope := "playlist-modify-private"
+            if public {
+                scope = "playlist-modify-public"
+            }
+            if err := requireScopes(ctx, scope); err != nil {
+                return err
+            }
+
+            progress, err := loadImportProgress(ctx, content, args[0], imported.Name, public, collaborative)
+            if err != nil {
+                return err
+            }
+            switch {
+            case restart || len(progress.Uris) == 0 && !progress.Creating:
+                progress = &importProgress{path: progress.path, File: args[0]}
+            case progress.PlaylistId == "":
+                _, _ = fmt.Fprintln(ctx.Stderr, "Resuming import, "+strconv.Itoa(len(progress.Uris))+" of "+
+                    strconv.Itoa(len(imported.Tracks))+" items were looked up already")
+            default:
+                _, _ = fmt.Fprintln(ctx.Stderr, "Resuming import into "+progress.Name+", "+
+                    strconv.Itoa(progress.Added)+" of "+strconv.Itoa(len(progress.Uris))+" items were processed already")
+            }
+            if err := runImport(ctx, imported, progress, public, collaborative); err != nil {
+                // nothing to resume before the first item was looked up
+                if (len(progress.Uris) > 0 || progress.Creating) && progress.save() == nil {
+                    _, _ = fmt.Fprintln(ctx.Stderr, "Import stopped, run the same command again to resume it")
+                }
+                return err
+            }
+            if err := progress.remove(); err != nil {
+                return err
+            }
+
+            found := 0
+            for _, uri := range progress.Uris {
+                if uri != "" {
+                    found++
+                }
+            }
+            return ctx.Report(actionResult{
+                Action: "import",
+                Uri:    progress.PlaylistUri,
+                Name:   progress.Name,
+                Message: "Imported " + strconv.Itoa(found) + " of " + strconv.Itoa(len(imported.Tracks)) +
+                    " items into " + progress.Name + " (" + progress.PlaylistUri + ")",
+            })
+        },
+    }
+}
+
+// runImport finds URIs of items, creates the playlist and adds them, recording in
+// progress how far it got
+func runImport(ctx *Context, imported *exportedPlaylist, progress *importProgress, public bool, collaborative bool) error {
+    // looking items up is the slow part, so what was found is saved every now and then
+    // and the next run goes on from there
+    for i := len(progress.Uris); i < len(imported.Tracks); i++ {
+        t := imported.Tracks[i]
+        uri, err := findExportedTrack(ctx, t)
+        if err != nil {
+            return err
+        }
+        if uri == "" {
+            _, _ = fmt.Fprintln(ctx.Stderr, "Skipping "+joinNonEmpty(" — ", t.Name, strings.Join(t.Artists, ", "))+", nothing found")
+        }
+        progress.Uris = append(progress.Uris, uri)
+        if len(progress.Uris)%importSaveEvery == 0 {
+            if err := progress.save(); err != nil {
+                return err
+            }
+        }
+    }
+
+    if progress.PlaylistId == "" {
+        if err := createImportPlaylist(ctx, imported, progress, public, collaborative); err != nil {
+            return err
+        }
+    } else if err := skipLandedChunk(ctx, progress); err != nil {
+        return err
+    }
+
+    for progress.Added < len(progress.Uris) {
+        uris, end := progress.nextChunk()
+        if len(uris) > 0 {
+            snapshotId, err := ctx.Client.AddPlaylistItems(ctx, progress.PlaylistId, uris, -1)
+            if err != nil {
+                return err
+            }
+            progress.SnapshotId = snapshotId
+        }
+        progress.Added = end
+        if err := progress.save(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// importSaveEvery is how many looked up items are saved at once
+const importSaveEvery = 20
+
+// createImportPlaylist creates the playlist to import into. Creating is not retried as
+// it is not idempotent, so it is noted down first; if the response got lost, the next
+// run finds the playlist among user's ones instead of creating another.
+func createImportPlaylist(ctx *Context, imported *exportedPlaylist, progress *importProgress, public bool, collaborative bool) error {
+    userId, err := currentUserId(ctx)
+    if err != nil {
+        return err
+    }
+    var playlist *spotify.Playlist
+    if progress.Creating {
+        if playlist, err = findCreatedPlaylist(ctx, userId, imported.Name); err != nil {
+            return err
+        }
+    }
+    if playlist == nil {
+        progress.Creating, progress.Name = true, imported.Name
+        if err := progress.save(); err != nil {
+            return err
+        }
+        details := spotify.PlaylistDetails{Name: imported.Name, Public: &public}
+        if collaborative {
+            details.Collaborative = &collaborative
+        }
+        if imported.Description != "" {
+            details.Description = &imported.Description
+        }
+        if playlist, err = ctx.Client.CreatePlaylist(ctx, userId, details); err != nil {
+            return err
+        }
+    }
+    progress.PlaylistId, progress.PlaylistUri, progress.Name = playlist.Id, playlist.Uri, playlist.Name
+    progress.SnapshotId, progress.Creating = playlist.SnapshotId, false
+    return progress.save()
+}
+
+// findCreatedPlaylist looks for an empty playlist of the name owned by the user among the
+// most recent ones, which is where a just created playlist is
+func findCreatedPlaylist(ctx *Context, userId string, name string) (*spotify.Playlist, error) {
+    page, err := ctx.Client.CurrentUserPlaylists(ctx, 50, 0)
+    if err != nil {
+        return nil, err
+    }
+    for i := range page.Items {
+        p := &page.Items[i]
+        if p.Name == name && p.Owner.Id == userId && (p.Tracks == nil || p.Tracks.Total == 0) {
+            return p, nil
+        }
+    }
+    return nil, nil
+}
+
+// skipLandedChunk finds out whether the chunk that failed last time was added anyway.
+// Adding is not retried as it is not idempotent, so a lost response leaves the chunk in
+// the playlist but not in progress; the playlist has then exactly that many more items.
+func skipLandedChunk(ctx *Context, progress *importProgress) error {
+    if progress.Added >= len(progress.Uris) {
+        return nil
+    }
+    playlist, err := ctx.Client.Playlist(ctx, progress.PlaylistId, "snapshot_id,tracks(total)")
+    if err != nil {
+        return err
+    }
+    if playlist.SnapshotId == progress.SnapshotId || playlist.Tracks == nil {
+        return nil
+    }
+    added := 0
+    for _, uri := range progress.Uris[:progress.Added] {
+        if uri != "" {
+            added++
+        }
+    }
+    uris, end := progress.nextChunk()
+    switch playlist.Tracks.Total {
+    case added:
+        return nil
+    case added + len(uris):
+        progress.Added, progress.SnapshotId = end, playlist.SnapshotId
+        return progress.save()
+    }
+    return errors.New("playlist " + progress.Name + " was changed while importing, it has " +
+        strconv.Itoa(playlist.Tracks.Total) + " items instead of " + strconv.Itoa(added) +
+        "; check it and fix it by hand, or import again with --restart")
+}
+
+// findExportedTrack gives URI of the item: the one in the file if it is valid, otherwise
+// the best match by ISRC or by artist and name. Empty URI means nothing was found.
+func findExportedTrack(ctx *Context, t exportedTrack) (string, error) {
+    if uri, err := spotify.ParseUri(t.Uri); err == nil {
+        if kind, _ := spotify.SplitUri(uri); kind == "track" || kind == "episode" {
+            return uri, nil
+        }
+    }
+    var queries []string
+    if t.Isrc != "" {
+        queries = append(queries, "isrc:"+t.Isrc)
+    }
+    if t.Name != "" {
+        query := withFilter("", "track", t.Name)
+        if len(t.Artists) > 0 {
+            query = withFilter(query, "artist", t.Artists[0])
+        }
+        queries = append(queries, query)
+    }
+    for _, query := range queries {
+        result, err := ctx.Client.Search(ctx, query, []string{"track"}, 1, 0)
+        if err != nil {
+            return "", err
+        }
+        if result.Tracks != nil && len(result.Tracks.Items) > 0 {
+            return result.Tracks.Items[0].Uri, nil
+        }
+    }
+    return "", nil
+}
+
+// importProgress is kept in the state directory while import of a file is unfinished,
+// under a name derived from the file content. Uris are found items in order of the file,
+// empty for items not found; Added is how many of them went to the playlist already.
+type importProgress struct {
+    File        string   `json:"file"`
+    PlaylistId  string   `json:"playlist_id"`
+    PlaylistUri string   `json:"playlist_uri"`
+    Name        string   `json:"name"`
+    Uris        []string `json:"uris"`
+    Added       int      `json:"added"`
+    // SnapshotId is the snapshot after the last chunk we know was added
+    SnapshotId string `json:"snapshot_id"`
+    // Creating is set while the playlist is being created, see createImportPlaylist
+    Creating bool `json:"creating,omitempty"`
+
+    path string
+}
+
+// nextChunk gives URIs to add after Added, skipping items that were not found, and where
+// the chunk ends in Uris
+func (p *importProgress) nextChunk() (uris []string, end int) {
+    end = p.Added
+    for ; end < len(p.Uris) && len(uris) < spotify.MaxPlaylistItems; end++ {
+        if p.Uris[end] != "" {
+            uris = append(uris, p.Uris[end])
+        }
+    }
+    return uris, end
+}
+
+// loadImportProgress finds unfinished import of the same content from the same file into
+// a playlist of the same name and visibility, importing it differently starts over
+func loadImportProgress(ctx *Context, content []byte, file string, name string, public bool, collaborative bool) (*importProgress, error) {
+    dir, err := profileStateDir(ctx.Config.active)
+    if err != nil {
+        return nil, err
+    }
+    if file != "-" {
+        if abs, err := filepath.Abs(file); err == nil {
+            file = abs
+        }
+    }
+    hash := sha256.New()
+    for _, part := range []string{ctx.Config.active, file, name, strconv.FormatBool(public), strconv.FormatBool(collaborative)} {
+        _, _ = hash.Write([]byte(part + "\x00"))
+    }
+    _, _ = hash.Write(content)
+    p := &importProgress{path: filepath.Join(dir, "imports", hex.EncodeToString(hash.Sum(nil)[:8])+".json")}
+    saved, err := ioutil.ReadFile(p.path)
+    if os.IsNotExist(err) {
+        return p, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if jsonErr := json.Unmarshal(saved, p); jsonErr != nil {
+        return nil, errors.New("malformed import progress file " + p.path + ", remove it or use --restart")
+    }
+    return p, nil
+}
+
+func (p *importProgress) save() error {
+    if err := os.MkdirAll(filepath.Dir(p.path), 0700); err != nil {
+        return err
+    }
+    content, err := json.MarshalIndent(p, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(p.path, content)
+}
+
+func (p *importProgress) remove() error {
+    if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    return nil
+}
+
+// readInput reads the whole file, "-" is stdin
+func readInput(ctx *Context, path string) ([]byte, error) {
+    if path == "-" {
+        return ioutil.ReadAll(ctx.Stdin)
+    }
+    return ioutil.ReadFile(path)
+}
+
+func checkPlaylistFileFormat(format string) error {
+    for _, f := range playlistFileFormats {
+        if f == format {
+            return nil
+        }
+    }
+    return errors.New("unknown format \"" + format + "\", expected one of " + strings.Join(playlistFileFormats, ", "))
+}
+
+// formatOfPath is the format by file extension, JSON if there is none that we know
+func formatOfPath(path string) string {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".csv":
+        return PlaylistCsv
+    case ".m3u", ".m3u8":
+        return PlaylistM3u
+    }
+    return PlaylistJson
+}
+
+// detectPlaylistFormat looks at the extension first and at the content if it tells nothing
+func detectPlaylistFormat(path string, content []byte) string {
+    switch ext := strings.ToLower(filepath.Ext(path)); ext {
+    case ".json", ".csv", ".m3u", ".m3u8":
+        return formatOfPath(path)
+    }
+    trimmed := bytes.TrimSpace(content)
+    switch {
+    case bytes.HasPrefix(trimmed, []byte("{")):
+        return PlaylistJson
+    case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+        return PlaylistM3u
+    }
+    return PlaylistCsv
+}
+
+func writePlaylistFile(w io.Writer, format string, p *exportedPlaylist) error {
+    switch format {
+    case PlaylistCsv:
+        out := csv.NewWriter(w)
+        _ = out.Write(csvColumns)
+        for _, t := range p.Tracks {
+            _ = out.Write([]string{t.Uri, t.Name, strings.Join(t.Artists, "; "), t.Album, t.Isrc, strconv.Itoa(t.DurationMs)})
+        }
+        out.Flush()
+        return out.Error()
+    case PlaylistM3u:
+        lines := []string{"#EXTM3U", "#PLAYLIST:" + p.Name}
+        for _, t := range p.Tracks {
+            lines = append(lines, "#EXTINF:"+strconv.Itoa(t.DurationMs/1000)+","+joinNonEmpty(" - ", strings.Join(t.Artists, ", "), t.Name))
+            if t.Album != "" {
+                lines = append(lines, "#EXTALB:"+t.Album)
+            }
+            if t.Isrc != "" {
+                lines = append(lines, "#EXTISRC:"+t.Isrc)
+            }
+            lines = append(lines, t.Uri)
+        }
+        _, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+        return err
+    }
+    if p.Tracks == nil {
+        p.Tracks = []exportedTrack{}
+    }
+    content, err := json.MarshalIndent(p, "", "  ")
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(append(content, '\n'))
+    return err
+}
+
+func readPlaylistFile(content []byte, format string) (*exportedPlaylist, error) {
+    switch format {
+    case PlaylistCsv:
+        return readPlaylistCsv(content)
+    case PlaylistM3u:
+        return readPlaylistM3u(content), nil
+    }
+    var p exportedPlaylist
+    if err := json.Unmarshal(content, &p); err != nil {
+        return nil, err
+    }
+    return &p, nil
+}
+
+func readPlaylistCsv(content []byte) (*exportedPlaylist, error) {
+    records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+    if err != nil {
+        return nil, err
+    }
+    if len(records) == 0 {
+        return &exportedPlaylist{}, nil
+    }
+    columns := map[string]int{}
+    for i, name := range records[0] {
+        name = strings.ToLower(strings.TrimSpace(name))
+        switch name {
+        case "artist":
+            name = "artists"
+        case "title", "track":
+            name = "name"
+        }
+        columns[name] = i
+    }
+    if _, ok := columns["uri"]; !ok {
+        if _, ok := columns["name"]; !ok {
+            return nil, errors.New("header must have uri or name column, e.g. " + strings.Join(csvColumns, ","))
+        }
+    }
+    value := func(record []string, column string) string {
+        if i, ok := columns[column]; ok && i < len(record) {
+            return strings.TrimSpace(record[i])
+        }
+        return ""
+    }
+
+    p := &exportedPlaylist{}
+    for _, record := range records[1:] {
+        t := exportedTrack{
+            Uri:   value(record, "uri"),
+            Name:  value(record, "name"),
+            Album: value(record, "album"),
+            Isrc:  value(record, "isrc"),
+        }
+        for _, a := range strings.Split(value(record, "artists"), ";") {
+            if a = strings.TrimSpace(a); a != "" {
+                t.Artists = append(t.Artists, a)
+            }
+        }
+        t.DurationMs, _ = strconv.Atoi(value(record, "duration_ms"))
+        p.Tracks = append(p.Tracks, t)
+    }
+    return p, nil
+}
+
+// readPlaylistM3u takes URIs and links from locations, anything else (e.g. local file
+// paths) is found by "#EXTINF:<seconds>,<artists> - <name>" lines
+func readPlaylistM3u(content []byte) *exportedPlaylist {
+    p := &exportedPlaylist{}
+    var t exportedTrack
+    scanner := bufio.NewScanner(bytes.NewReader(content))
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        switch {
+        case line == "":
+        case strings.HasPrefix(line, "#PLAYLIST:"):
+            p.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+        case strings.HasPrefix(line, "#EXTINF:"):
+            info := strings.TrimPrefix(line, "#EXTINF:")
+            if i := strings.Index(info, ","); i >= 0 {
+                if seconds, err := strconv.Atoi(strings.TrimSpace(info[:i])); err == nil && seconds > 0 {
+                    t.DurationMs = seconds * 1000
+                }
+                info = info[i+1:]
+            }
+            t.Name = strings.TrimSpace(info)
+            if i := strings.Index(info, " - "); i >= 0 {
+                t.Artists = strings.Split(strings.TrimSpace(info[:i]), ", ")
+                t.Name = strings.TrimSpace(info[i+3:])
+            }
+        case strings.HasPrefix(line, "#EXTALB:"):
+            t.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+        case strings.HasPrefix(line, "#EXTISRC:"):
+            t.Isrc = strings.TrimSpace(strings.TrimPrefix(line, "#EXTISRC:"))
+        case strings.HasPrefix(line, "#"):
+        default:
+            if uri, err := spotify.ParseUri(line); err == nil {
+                t.Uri = uri
+            }
+            p.Tracks = append(p.Tracks, t)
+            t = exportedTrack{}
+        }
+    }
+    return p
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "sort"
+
+    "spotify/spotify"
+)
+
+const DefaultProfile = "default"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// selectProfile makes the profile active; without a name SPOTIFY_CLI_PROFILE is used,
+// then the one chosen by `spotify profile use`, then the default one. The profile may
+// not exist, commands using it check that with checkProfile.
+func selectProfile(c *Config, name string) {
+    if name == "" {
+        name = os.Getenv("SPOTIFY_CLI_PROFILE")
+    }
+    if name == "" {
+        name = c.Profile
+    }
+    if name == "" {
+        name = DefaultProfile
+    }
+    c.active = name
+}
+
+// checkProfile fails when the active profile does not exist, so that typos do not end
+// up with a fresh login or settings nobody will find
+func checkProfile(c *Config) error {
+    if _, ok := c.Profiles[c.active]; !ok && c.active != DefaultProfile {
+        return errors.New("no profile \"" + c.active + "\", see `spotify profile list`")
+    }
+    return nil
+}
+
+// profileNames lists the default profile first, then the others by name
+func profileNames(c *Config) []string {
+    names := []string{DefaultProfile}
+    for name := range c.Profiles {
+        names = append(names, name)
+    }
+    sort.Strings(names[1:])
+    return names
+}
+
+func profileCommand() *Command {
+    return &Command{
+        Name: "profile",
+        Description: "Manage profiles, e.g. for several people sharing one machine\n" +
+            "Every profile has its own login and settings (see `spotify config`), settings it does\n" +
+            "not set are taken from the default profile. Commands use the profile given by\n" +
+            "--profile, SPOTIFY_CLI_PROFILE or `spotify profile use`, in that order.",
+        Run:        listProfiles,
+        AnyProfile: true,
+        Subcommands: []*Command{
+            {
+                Name:        "list",
+                Aliases:     []string{"ls"},
+                Description: "List profiles and accounts logged in to them",
+                Run:         listProfiles,
+            },
+            profileAddCommand(),
+            {
+                Name:        "remove",
+                Aliases:     []string{"rm"},
+                Description: "Remove profile together with its login",
+                Args:        []Arg{{Name: "name", Description: "profile to remove"}},
+                Run: func(ctx *Context, args []string) error {
+                    name := args[0]
+                    if name == DefaultProfile {
+                        return usageErrorf(ctx.Command, "default profile cannot be removed")
+                    }
+                    if _, ok := ctx.Config.Profiles[name]; !ok {
+                        return errors.New("no profile \"" + name + "\", see `spotify profile list`")
+                    }
+                    delete(ctx.Config.Profiles, name)
+                    if ctx.Config.Profile == name {
+                        ctx.Config.Profile = ""
+                    }
+                    if err := saveConfig(ctx.Config); err != nil {
+                        return err
+                    }
+                    dir, err := profileStateDir(name)
+                    if err != nil {
+                        return err
+                    }
+                    if err := os.RemoveAll(dir); err != nil {
+                        return err
+                    }
+                    return ctx.Report(actionResult{Action: "remove", Name: name, Message: "Profile " + name + " removed"})
+                },
+            },
+            {
+                Name:        "use",
+                Description: "Use the profile from now on",
+                Args:        []Arg{{Name: "name", Description: "profile to use"}},
+                Run: func(ctx *Context, args []string) error {
+                    name := args[0]
+                    if _, ok := ctx.Config.Profiles[name]; !ok && name != DefaultProfile {
+                        return errors.New("no profile \"" + name + "\", see `spotify profile list`")
+                    }
+                    ctx.Config.Profile = name
+                    if name == DefaultProfile {
+                        ctx.Config.Profile = ""
+                    }
+                    if err := saveConfig(ctx.Config); err != nil {
+                        return err
+                    }
+                    if os.Getenv("SPOTIFY_CLI_PROFILE") != "" {
+                        _, _ = fmt.Fprintln(ctx.Stderr, "Note that SPOTIFY_CLI_PROFILE is set and takes precedence")
+                    }
+                    return ctx.Report(actionResult{Action: "use", Name: name, Message: "Using profile " + name})
+                },
+            },
+        },
+    }
+}
+
+func profileAddCommand() *Command {
+    var use bool
+    return &Command{
+        Name:        "add",
+        Description: "Add a profile, log in to it with `spotify --profile <name> login`",
+        Args:        []Arg{{Name: "name", Description: "letters, digits, - and _"}},
+        Flags: func(fs *flag.FlagSet) {
+            fs.BoolVar(&use, "use", false, "use the profile from now on")
+        },
+        Run: func(ctx *Context, args []string) error {
+            name := args[0]
+            if !profileNamePattern.MatchString(name) {
+                return usageErrorf(ctx.Command, "profile name can only have letters, digits, - and _")
+            }
+            if _, ok := ctx.Config.Profiles[name]; ok || name == DefaultProfile {
+                return errors.New("profile \"" + name + "\" already exists")
+            }
+            if ctx.Config.Profiles == nil {
+                ctx.Config.Profiles = map[string]*Settings{}
+            }
+            ctx.Config.Profiles[name] = &Settings{}
+            if use {
+                ctx.Config.Profile = name
+            }
+            if err := saveConfig(ctx.Config); err != nil {
+                return err
+            }
+            return ctx.Report(actionResult{
+                Action:  "add",
+                Name:    name,
+                Message: "Profile " + name + " added, log in with `spotify --profile " + name + " login`",
+            })
+        },
+    }
+}
+
+type profileResult struct {
+    Name      string `json:"name"`
+    Active    bool   `json:"active"`
+    LoggedIn  bool   `json:"logged_in"`
+    AccountId string `json:"account_id"`
+}
+
+func listProfiles(ctx *Context, args []string) error {
+    var results []profileResult
+    for _, name := range profileNames(ctx.Config) {
+        store, err := profileCredentialStore(name)
+        if err != nil {
+            return err
+        }
+        c, err := store.Load()
+        if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+            return err
+        }
+        result := profileResult{Name: name, Active: name == ctx.Config.active, LoggedIn: c != nil}
+        if c != nil {
+            result.AccountId = c.AccountId
+        }
+        results = append(results, result)
+    }
+
+    return ctx.Render(results, func(w io.Writer) {
+        for _, r := range results {
+            marker := " "
+            if r.Active {
+                marker = "*"
+            }
+            account := "not logged in"
+            if r.LoggedIn {
+                account = "logged in"
+            }
+            if r.AccountId != "" {
+                account = "logged in as " + r.AccountId
+            }
+            _, _ = fmt.Fprintf(w, "%s %-12s %s\n", marker, r.Name, account)
+        }
+    })
+}
+
+type whoamiResult struct {
+    Id          string `json:"id"`
+    DisplayName string `json:"display_name"`
+    Profile     string `json:"profile"`
+}
+
+func whoamiCommand() *Command {
+    return &Command{
+        Name:        "whoami",
+        Description: "Show Spotify account of the profile in use",
+        Run: func(ctx *Context, args []string) error {
+            user, err := ctx.Client.CurrentUser(ctx)
+            if err != nil {
+                return err
+            }
+            result := whoamiResult{Id: user.Id, DisplayName: user.DisplayName, Profile: ctx.Config.active}
+            return ctx.Render(result, func(w io.Writer) {
+                _, _ = fmt.Fprintln(w, describeUser(user)+" (profile "+ctx.Config.active+")")
+            })
+        },
+    }
+}
+
+func describeUser(user *spotify.User) string {
+    if user.DisplayName == "" || user.DisplayName == user.Id {
+        return user.Id
+    }
+    return user.DisplayName + " [" + user.Id + "]"
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// printNumbered prints items as the numbered list user picks from with promptIndex
+func printNumbered(w io.Writer, items []string) {
+    for i, v := range items {
+        _, _ = fmt.Fprintln(w, "["+strconv.Itoa(i)+"] "+v)
+    }
+}
+
+// printMoreHint tells how to get the next page when a list has more items than shown
+func printMoreHint(ctx *Context, offset int, shown int, total int) {
+    _, _ = fmt.Fprintln(ctx.Stderr, "Showing "+strconv.Itoa(offset+1)+"-"+strconv.Itoa(offset+shown)+
+        " of "+strconv.Itoa(total)+", use --offset "+strconv.Itoa(offset+shown)+" for more")
+}
+
+// promptIndex asks user to pick one of n numbered items and returns its index, or -1
+// if user entered nothing
+func promptIndex(ctx *Context, prompt string, n int) (int, error) {
+    _, _ = fmt.Fprint(ctx.Stderr, prompt)
+    reader := bufio.NewReader(ctx.Stdin)
+    text, err := reader.ReadString('\n')
+    if err != nil && err != io.EOF {
+        return -1, fmt.Errorf("cannot read this line: %w", err)
+    }
+    text = strings.TrimSpace(text)
+    if text == "" {
+        return -1, nil
+    }
+    i, err := strconv.Atoi(text)
+    if err != nil || i < 0 || i > n-1 {
+        return -1, fmt.Errorf("malformed input %q, expected number from 0 to %d", text, n-1)
+    }
+    return i, nil
+}
+
+// isInteractive reports whether user can answer prompts, i.e. stdin is a terminal and
+// output is not meant for a program
+func isInteractive(ctx *Context) bool {
+    if ctx.machineOutput() {
+        return false
+    }
+    f, ok := ctx.Stdin.(*os.File)
+    if !ok {
+        return false
+    }
+    fi, err := f.Stat()
+    return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "spotify/spotify"
+)
+
+func queueCommand() *Command {
+    return &Command{
+        Name:        "queue",
+        Description: "Manage playback queue, shows it without a command",
+        Run:         listQueue,
+        Subcommands: []*Command{
+            queueAddCommand(),
+            queueListCommand(),
+            queueClearCommand(),
+        },
+    }
+}
+
+func queueAddCommand() *Command {
+    var device, file string
+    return &Command{
+        Name: "add",
+        Description: "Add tracks or episodes to the end of the queue\n" +
+            "Several URIs or links are added in order; anything else is searched for and the best\n" +
+            "matching track is added. With --next-n every line of the file (\"-\" for stdin) is\n" +
+            "added in order, so they play as the next N items; empty lines and #comments are skipped.",
+        Args: []Arg{
+            {Name: "uri|url|query", Description: "what to add", Optional: true, Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&device, "device", "", "`name or id` of the device to queue on instead of the active one")
+            fs.StringVar(&file, "next-n", "", "add every line of the `file`, \"-\" for stdin")
+        },
+        Run: func(ctx *Context, args []string) error {
+            var entries []string
+            if uris, ok := allUris(args); ok {
+                entries = uris
+            } else {
+                entries = []string{strings.Join(args, " ")}
+            }
+            if file != "" {
+                lines, err := readEntries(ctx, file)
+                if err != nil {
+                    return err
+                }
+                entries = append(entries, lines...)
+            }
+            if len(entries) == 0 {
+                return usageErrorf(ctx.Command, "nothing to add, give URI, query or --next-n file")
+            }
+
+            deviceId := ""
+            if device != "" {
+                d, err := resolveDevice(ctx, device)
+                if err != nil {
+                    return err
+                }
+                deviceId = d.Id
+            }
+
+            queued := []actionResult{}
+            for i, entry := range entries {
+                uri, name, err := resolveQueueEntry(ctx, entry)
+                if err == nil {
+                    err = onChosenDevice(ctx, deviceId, func(id string) error {
+                        if err := ctx.Client.AddToQueue(ctx, id, uri); err != nil {
+                            return err
+                        }
+                        // the rest goes where the first one went
+                        deviceId = id
+                        return nil
+                    })
+                }
+                if err != nil {
+                    // what got queued is still worth reporting
+                    _ = renderQueued(ctx, queued)
+                    return fmt.Errorf("queued %d of %d, %q failed: %w", i, len(entries), entry, err)
+                }
+                queued = append(queued, actionResult{Action: "queue", Uri: uri, Name: name, Message: "Queued " + name})
+            }
+            return renderQueued(ctx, queued)
+        },
+    }
+}
+
+func renderQueued(ctx *Context, queued []actionResult) error {
+    return ctx.Render(queued, func(w io.Writer) {
+        for _, q := range queued {
+            _, _ = fmt.Fprintln(w, q.Message)
+        }
+    })
+}
+
+func queueListCommand() *Command {
+    return &Command{
+        Name:        "list",
+        Aliases:     []string{"ls"},
+        Description: "Show what is playing now and what comes next",
+        Run:         listQueue,
+    }
+}
+
+type skipResult struct {
+    Skipped int `json:"skipped"`
+}
+
+func queueClearCommand() *Command {
+    var count int
+    var device string
+    return &Command{
+        Name: "clear",
+        Description: "Skip past queued items\n" +
+            "Spotify has no way to remove items from the queue, so they are skipped instead. It does\n" +
+            "not tell queued items from ones coming next in context either, so everything listed by\n" +
+            "`queue list` is skipped unless --count says how many.",
+        Flags: func(fs *flag.FlagSet) {
+            fs.IntVar(&count, "count", 0, "skip this `number` of items instead of the whole queue")
+            fs.StringVar(&device, "device", "", "`name or id` of the device to skip on instead of the active one")
+        },
+        Run: func(ctx *Context, args []string) error {
+            if count <= 0 {
+                queue, err := ctx.Client.Queue(ctx)
+                if err != nil {
+                    return err
+                }
+                count = len(queue.Queue)
+            }
+            deviceId := ""
+            if device != "" {
+                d, err := resolveDevice(ctx, device)
+                if err != nil {
+                    return err
+                }
+                deviceId = d.Id
+            }
+            for i := 0; i < count; i++ {
+                err := onChosenDevice(ctx, deviceId, func(id string) error {
+                    if err := ctx.Client.Next(ctx, id); err != nil {
+                        return err
+                    }
+                    deviceId = id
+                    return nil
+                })
+                if err != nil {
+                    return fmt.Errorf("skipped %d of %d: %w", i, count, err)
+                }
+            }
+
+            return ctx.Render(skipResult{Skipped: count}, func(w io.Writer) {
+                _, _ = fmt.Fprintf(w, "Skipped %d items\n", count)
+            })
+        },
+    }
+}
+
+type queueResult struct {
+    CurrentlyPlaying *itemResult   `json:"currently_playing"`
+    Queue            []*itemResult `json:"queue"`
+}
+
+type queueRow struct {
+    // Position is 0 for the currently playing item and counts queued ones from 1
+    Position int `json:"position"`
+    *itemResult
+}
+
+func (r queueResult) Rows() interface{} {
+    rows := []queueRow{}
+    if r.CurrentlyPlaying != nil {
+        rows = append(rows, queueRow{Position: 0, itemResult: r.CurrentlyPlaying})
+    }
+    for i, item := range r.Queue {
+        rows = append(rows, queueRow{Position: i + 1, itemResult: item})
+    }
+    return rows
+}
+
+func listQueue(ctx *Context, args []string) error {
+    queue, err := ctx.Client.Queue(ctx)
+    if err != nil {
+        return err
+    }
+    result := queueResult{Queue: []*itemResult{}}
+    if queue.CurrentlyPlaying != nil {
+        result.CurrentlyPlaying = newItemResult(queue.CurrentlyPlaying)
+    }
+    for i := range queue.Queue {
+        result.Queue = append(result.Queue, newItemResult(&queue.Queue[i]))
+    }
+
+    return ctx.Render(result, func(w io.Writer) {
+        if queue.CurrentlyPlaying == nil {
+            _, _ = fmt.Fprintln(w, "Nothing is playing right now")
+        } else {
+            _, _ = fmt.Fprintln(w, "Now playing: "+describeItem(queue.CurrentlyPlaying))
+        }
+        if len(queue.Queue) == 0 {
+            _, _ = fmt.Fprintln(w, "Queue is empty")
+            return
+        }
+        _, _ = fmt.Fprintln(w, "Next up:")
+        items := make([]string, 0, len(queue.Queue))
+        for i := range queue.Queue {
+            items = append(items, describeItem(&queue.Queue[i]))
+        }
+        printNumbered(w, items)
+    })
+}
+
+func describeItem(item *spotify.Track) string {
+    by, _ := itemSubtitle(item)
+    return joinNonEmpty(" — ", item.Name, by)
+}
+
+// allUris parses every arg as URI or link, ok is false if any of them is something else
+func allUris(args []string) (uris []string, ok bool) {
+    for _, a := range args {
+        uri, err := spotify.ParseUri(a)
+        if err != nil {
+            return nil, false
+        }
+        uris = append(uris, uri)
+    }
+    return uris, true
+}
+
+// resolveQueueEntry turns URI, link or query into URI of a track or episode
+func resolveQueueEntry(ctx *Context, entry string) (uri string, name string, err error) {
+    uri, err = spotify.ParseUri(entry)
+    if err == spotify.ErrNotUri {
+        item, err := searchBest(ctx, entry, "track")
+        if err != nil {
+            return "", "", err
+        }
+        return item.Uri, item.Type + " " + item.Name, nil
+    }
+    if kind, _ := spotify.SplitUri(uri); kind != "track" && kind != "episode" {
+        return "", "", fmt.Errorf("only tracks and episodes can be queued, not %s", kind)
+    }
+    return uri, uri, nil
+}
+
+// readEntries reads non-empty lines of the file, or of stdin for "-", skipping #comments
+func readEntries(ctx *Context, file string) ([]string, error) {
+    var r io.Reader = ctx.Stdin
+    if file != "-" {
+        f, err := os.Open(file)
+        if err != nil {
+            return nil, err
+        }
+        defer f.Close()
+        r = f
+    }
+    var lines []string
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line != "" && !strings.HasPrefix(line, "#") {
+            lines = append(lines, line)
+        }
+    }
+    return lines, scanner.Err()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "strings"
+
+    "spotify/spotify"
+)
+
+// searchItem is a search result of any type, flattened so that results of all types
+// can be numbered in one list
+type searchItem struct {
+    Type   string `json:"type"`
+    Name   string `json:"name"`
+    Detail string `json:"detail"`
+    Uri    string `json:"uri"`
+}
+
+func searchCommand() *Command {
+    var kinds, artist, album, year, genre string
+    var limit, pick int
+    return &Command{
+        Name: "search",
+        Description: "Search the catalog and pick a result to play\n" +
+            "Query may contain field filters, e.g. `spotify search love artist:adele year:2010-2016`,\n" +
+            "or they can be given with flags. Results are numbered; in a terminal you are asked\n" +
+            "which one to play, elsewhere use --pick.",
+        Args: []Arg{
+            {Name: "query", Description: "what to search for", Optional: true, Variadic: true},
+        },
+        Flags: func(fs *flag.FlagSet) {
+            fs.StringVar(&kinds, "type", "track", "comma separated `types` to search: "+strings.Join(spotify.SearchTypes, ", "))
+            fs.IntVar(&limit, "limit", 10, "number of results of each type, 1-50")
+            fs.IntVar(&pick, "pick", -1, "play result with this `number` without asking")
+            fs.StringVar(&artist, "artist", "", "only results by this `artist`")
+            fs.StringVar(&album, "album", "", "only results from this `album`")
+            fs.StringVar(&year, "year", "", "only results from this `year` or range of years, e.g. 1990-1999")
+            fs.StringVar(&genre, "genre", "", "only results of this `genre`")
+        },
+        Run: func(ctx *Context, args []string) error {
+            query := strings.Join(args, " ")
+            for _, f := range [][2]string{{"artist", artist}, {"album", album}, {"year", year}, {"genre", genre}} {
+                query = withFilter(query, f[0], f[1])
+            }
+            if query == "" {
+                return usageErrorf(ctx.Command, "missing <query>")
+            }
+            types, err := parseSearchTypes(kinds)
+            if err != nil {
+                return usageErrorf(ctx.Command, "%s", err.Error())
+            }
+            if limit < 1 || limit > 50 {
+                return usageErrorf(ctx.Command, "limit must be within 1-50")
+            }
+
+            result, err := ctx.Client.Search(ctx, query, types, limit, 0)
+            if err != nil {
+                return err
+            }
+            items := searchItems(result)
+            // programs get either results or what was played, people see results first
+            if pick < 0 || !ctx.machineOutput() {
+                err := ctx.Render(items, func(w io.Writer) {
+                    printSearchItems(w, items, len(types) > 1)
+                })
+                if err != nil || len(items) == 0 {
+                    return err
+                }
+            }
+
+            if pick < 0 && isInteractive(ctx) {
+                if pick, err = promptIndex(ctx, "Play result by its number (enter to skip): ", len(items)); err != nil {
+                    return err
+                }
+            }
+            if pick < 0 {
+                return nil
+            }
+            if pick >= len(items) {
+                return usageErrorf(ctx.Command, "there is no result number %d", pick)
+            }
+            if err := playUri(ctx, items[pick].Uri); err != nil {
+                return err
+            }
+
+            return ctx.Report(actionResult{
+                Action:  "play",
+                Uri:     items[pick].Uri,
+                Name:    items[pick].Name,
+                Message: "Playing " + items[pick].Type + " " + items[pick].Name,
+            })
+        },
+    }
+}
+
+func printSearchItems(w io.Writer, items []searchItem, withKind bool) {
+    if len(items) == 0 {
+        _, _ = fmt.Fprintln(w, "Nothing found")
+        return
+    }
+    lines := make([]string, 0, len(items))
+    for _, item := range items {
+        line := item.Name
+        if item.Detail != "" {
+            line += " — " + item.Detail
+        }
+        if withKind {
+            line += " (" + item.Type + ")"
+        }
+        lines = append(lines, line)
+    }
+    printNumbered(w, lines)
+}
+
+// withFilter appends field filter to the query, quoting values with spaces
+func withFilter(query string, field string, value string) string {
+    if value == "" {
+        return query
+    }
+    if strings.Contains(value, " ") {
+        value = "\"" + value + "\""
+    }
+    return strings.TrimSpace(query + " " + field + ":" + value)
+}
+
+func parseSearchTypes(s string) ([]string, error) {
+    var types []string
+    for _, t := range strings.Split(s, ",") {
+        t = strings.TrimSpace(t)
+        known := false
+        for _, k := range spotify.SearchTypes {
+            known = known || k == t
+        }
+        if !known {
+            return nil, fmt.Errorf("unknown type %q, use %s", t, strings.Join(spotify.SearchTypes, ", "))
+        }
+        types = append(types, t)
+    }
+    return types, nil
+}
+
+// searchItems flattens search result in the order types are listed in spotify.SearchTypes
+func searchItems(result *spotify.SearchResult) []searchItem {
+    items := []searchItem{}
+    if result.Tracks != nil {
+        for _, t := range result.Tracks.Items {
+            by, from := itemSubtitle(&t)
+            items = append(items, searchItem{Type: "track", Name: t.Name, Detail: joinNonEmpty(", ", by, from), Uri: t.Uri})
+        }
+    }
+    if result.Albums != nil {
+        for _, a := range result.Albums.Items {
+            detail := strings.Join(artistNames(a.Artists), ", ")
+            if len(a.ReleaseDate) >= 4 {
+                detail = joinNonEmpty(", ", detail, a.ReleaseDate[:4])
+            }
+            items = append(items, searchItem{Type: "album", Name: a.Name, Detail: detail, Uri: a.Uri})
+        }
+    }
+    if result.Artists != nil {
+        for _, a := range result.Artists.Items {
+            items = append(items, searchItem{Type: "artist", Name: a.Name, Uri: a.Uri})
+        }
+    }
+    if result.Playlists != nil {
+        for _, p := range result.Playlists.Items {
+            // Spotify returns nulls for playlists that are not available anymore
+            if p.Uri == "" {
+                continue
+            }
+            items = append(items, searchItem{Type: "playlist", Name: p.Name, Detail: "by " + p.Owner.DisplayName, Uri: p.Uri})
+        }
+    }
+    if result.Shows != nil {
+        for _, s := range result.Shows.Items {
+            items = append(items, searchItem{Type: "show", Name: s.Name, Detail: s.Publisher, Uri: s.Uri})
+        }
+    }
+    if result.Episodes != nil {
+        for _, e := range result.Episodes.Items {
+            if e.Uri == "" {
+                continue
+            }
+            items = append(items, searchItem{Type: "episode", Name: e.Name, Detail: formatDuration(e.DurationMs), Uri: e.Uri})
+        }
+    }
+    return items
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+    var nonEmpty []string
+    for _, p := range parts {
+        if p != "" {
+            nonEmpty = append(nonEmpty, p)
+        }
+    }
+    return strings.Join(nonEmpty, sep)
+}
@@ -0,0 +1,28 @@
+package spotify
+
+import (
+    "context"
+    "net/url"
+)
+
+func (c *Client) Categories(ctx context.Context, limit int, offset int) (*CategoryPage, error) {
+    var resBody struct {
+        Categories CategoryPage `json:"categories"`
+    }
+    if err := c.do(ctx, "GET", "/browse/categories", pageQuery(limit, offset), nil, &resBody); err != nil {
+        return nil, err
+    }
+    return &resBody.Categories, nil
+}
+
+func (c *Client) CategoryPlaylists(ctx context.Context, categoryId string, limit int, offset int) (*PlaylistPage, error) {
+    var resBody struct {
+        Message   string       `json:"message"`
+        Playlists PlaylistPage `json:"playlists"`
+    }
+    path := "/browse/categories/" + url.PathEscape(categoryId) + "/playlists"
+    if err := c.do(ctx, "GET", path, pageQuery(limit, offset), nil, &resBody); err != nil {
+        return nil, err
+    }
+    return &resBody.Playlists, nil
+}
@@ -0,0 +1,44 @@
+package spotify
+
+import (
+    "context"
+    "net/url"
+)
+
+func (c *Client) Album(ctx context.Context, id string) (*Album, error) {
+    var album Album
+    if err := c.do(ctx, "GET", "/albums/"+url.PathEscape(id), nil, nil, &album); err != nil {
+        return nil, err
+    }
+    return &album, nil
+}
+
+func (c *Client) Artist(ctx context.Context, id string) (*Artist, error) {
+    var artist Artist
+    if err := c.do(ctx, "GET", "/artists/"+url.PathEscape(id), nil, nil, &artist); err != nil {
+        return nil, err
+    }
+    return &artist, nil
+}
+
+func (c *Client) Show(ctx context.Context, id string) (*Show, error) {
+    var show Show
+    if err := c.do(ctx, "GET", "/shows/"+url.PathEscape(id), nil, nil, &show); err != nil {
+        return nil, err
+    }
+    return &show, nil
+}
+
+// Playlist returns playlist details, fields limits the response to what is needed as
+// described in Spotify docs, empty means everything.
+func (c *Client) Playlist(ctx context.Context, id string, fields string) (*Playlist, error) {
+    var query url.Values
+    if fields != "" {
+        query = url.Values{"fields": {fields}}
+    }
+    var playlist Playlist
+    if err := c.do(ctx, "GET", "/playlists/"+url.PathEscape(id), query, nil, &playlist); err != nil {
+        return nil, err
+    }
+    return &playlist, nil
+}
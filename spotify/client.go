@@ -0,0 +1,164 @@
+// Package spotify is a small client for the parts of Spotify Web API the CLI uses.
+package spotify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+const DefaultBaseUrl = "https://api.spotify.com/v1"
+
+// TokenSource provides access token for every request. It is asked each time, so it
+// is the right place to refresh expired tokens.
+type TokenSource interface {
+    Token() (string, error)
+}
+
+// TokenSourceFunc adapts plain function to TokenSource.
+type TokenSourceFunc func() (string, error)
+
+func (f TokenSourceFunc) Token() (string, error) {
+    return f()
+}
+
+// StaticToken is a TokenSource that always returns the same token.
+type StaticToken string
+
+func (t StaticToken) Token() (string, error) {
+    return string(t), nil
+}
+
+// Client talks to Spotify Web API. BaseUrl can point to any server speaking the same
+// protocol, e.g. httptest.Server in tests.
+type Client struct {
+    BaseUrl    string
+    HttpClient *http.Client
+    Tokens     TokenSource
+    Retry      RetryPolicy
+}
+
+func NewClient(tokens TokenSource) *Client {
+    return &Client{
+        BaseUrl:    DefaultBaseUrl,
+        HttpClient: &http.Client{Timeout: 30 * time.Second},
+        Tokens:     tokens,
+        Retry:      DefaultRetryPolicy,
+    }
+}
+
+// do sends JSON encoded body (if any) to the path relative to BaseUrl and decodes
+// response into out (if any). Failed attempts are repeated according to Retry policy.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+    var jsonStr []byte
+    if body != nil {
+        var err error
+        if jsonStr, err = json.Marshal(body); err != nil {
+            return err
+        }
+    }
+
+    fullUrl := c.BaseUrl + path
+    if len(query) > 0 {
+        fullUrl += "?" + query.Encode()
+    }
+
+    for retry := 1; ; retry++ {
+        tempBody, err := c.send(ctx, method, path, fullUrl, jsonStr)
+        if err != nil {
+            d, ok := c.Retry.delay(retry, method, err)
+            if !ok || !sleep(ctx, d) {
+                return err
+            }
+            continue
+        }
+        if out == nil || len(tempBody) == 0 {
+            return nil
+        }
+        if jsonErr := json.Unmarshal(tempBody, out); jsonErr != nil {
+            return errors.New("cannot decode response of " + method + " " + path + ": " + jsonErr.Error())
+        }
+        return nil
+    }
+}
+
+// send makes a single attempt, unsuccessful response is returned as *APIError
+func (c *Client) send(ctx context.Context, method string, path string, fullUrl string, jsonStr []byte) ([]byte, error) {
+    var jsonParsed io.Reader
+    if jsonStr != nil {
+        jsonParsed = bytes.NewReader(jsonStr)
+    }
+    req, err := http.NewRequestWithContext(ctx, method, fullUrl, jsonParsed)
+    if err != nil {
+        return nil, err
+    }
+
+    token, err := c.Tokens.Token()
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Authorization", "Bearer "+token)
+    if jsonStr != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+
+    httpClient := c.HttpClient
+    if httpClient == nil {
+        httpClient = http.DefaultClient
+    }
+    response, err := httpClient.Do(req)
+    if err != nil {
+        return nil, &transportError{err}
+    }
+    defer response.Body.Close()
+
+    tempBody, err := ioutil.ReadAll(response.Body)
+    if err != nil {
+        return nil, &transportError{err}
+    }
+    if response.StatusCode >= 300 {
+        apiErr := newAPIError(method, path, response.StatusCode, tempBody)
+        apiErr.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+        return nil, apiErr
+    }
+    return tempBody, nil
+}
+
+// transportError marks failures of the network round trip itself, as opposed to
+// failures to build the request or get the token, which are not worth retrying
+type transportError struct {
+    err error
+}
+
+func (e *transportError) Error() string {
+    return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+    return e.err
+}
+
+func deviceQuery(deviceId string) url.Values {
+    if deviceId == "" {
+        return nil
+    }
+    return url.Values{"device_id": {deviceId}}
+}
+
+func pageQuery(limit int, offset int) url.Values {
+    query := url.Values{}
+    if limit > 0 {
+        query.Set("limit", strconv.Itoa(limit))
+    }
+    if offset > 0 {
+        query.Set("offset", strconv.Itoa(offset))
+    }
+    return query
+}
@@ -0,0 +1,91 @@
+package spotify
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Sentinel errors to check API errors against with errors.Is, every *APIError matches
+// at most one of them depending on status code and reason reported by Spotify.
+var (
+    ErrUnauthorized    = sentinel("unauthorized")
+    ErrNoActiveDevice  = sentinel("no active device")
+    ErrPremiumRequired = sentinel("premium required")
+    ErrRateLimited     = sentinel("rate limited")
+    ErrNotFound        = sentinel("not found")
+    ErrMissingScope    = sentinel("insufficient client scope")
+)
+
+type sentinel string
+
+func (s sentinel) Error() string {
+    return string(s)
+}
+
+// APIError is returned when Spotify responds with anything but success. Message and
+// Reason come from the error object Spotify puts into response body, Body keeps the
+// body as is for whatever else is needed. RetryAfter is set for rate limited requests.
+type APIError struct {
+    Method     string
+    Path       string
+    StatusCode int
+    Message    string
+    Reason     string
+    Body       []byte
+    RetryAfter time.Duration
+}
+
+func newAPIError(method string, path string, statusCode int, body []byte) *APIError {
+    apiErr := &APIError{
+        Method:     method,
+        Path:       path,
+        StatusCode: statusCode,
+        Body:       body,
+    }
+    var resBody struct {
+        Error struct {
+            Status  int    `json:"status"`
+            Message string `json:"message"`
+            Reason  string `json:"reason"`
+        } `json:"error"`
+    }
+    if jsonErr := json.Unmarshal(body, &resBody); jsonErr == nil {
+        apiErr.Message = resBody.Error.Message
+        apiErr.Reason = resBody.Error.Reason
+    }
+    return apiErr
+}
+
+func (e *APIError) Error() string {
+    s := e.Method + " " + e.Path + " returned " + strconv.Itoa(e.StatusCode)
+    if e.Message != "" {
+        s += ": " + e.Message
+    }
+    return s
+}
+
+// Is maps the error to sentinel errors, Spotify does not always fill reason in so
+// the message is checked too.
+func (e *APIError) Is(target error) bool {
+    message := strings.ToLower(e.Message)
+    switch target {
+    case ErrUnauthorized:
+        return e.StatusCode == http.StatusUnauthorized
+    case ErrNoActiveDevice:
+        return e.Reason == "NO_ACTIVE_DEVICE" ||
+            (e.StatusCode == http.StatusNotFound && strings.Contains(message, "no active device"))
+    case ErrPremiumRequired:
+        return e.Reason == "PREMIUM_REQUIRED" ||
+            (e.StatusCode == http.StatusForbidden && strings.Contains(message, "premium"))
+    case ErrRateLimited:
+        return e.StatusCode == http.StatusTooManyRequests
+    case ErrMissingScope:
+        return e.StatusCode == http.StatusForbidden && strings.Contains(message, "insufficient client scope")
+    case ErrNotFound:
+        return e.StatusCode == http.StatusNotFound && !e.Is(ErrNoActiveDevice)
+    }
+    return false
+}
@@ -0,0 +1,39 @@
+package spotify
+
+import (
+    "context"
+    "net/url"
+    "strings"
+)
+
+// MaxLibraryIds is how many ids library endpoints take in a single request
+const MaxLibraryIds = 50
+
+// SaveTracks adds tracks to Liked Songs of the user, at most MaxLibraryIds at once.
+func (c *Client) SaveTracks(ctx context.Context, ids []string) error {
+    return c.do(ctx, "PUT", "/me/tracks", url.Values{"ids": {strings.Join(ids, ",")}}, nil, nil)
+}
+
+// RemoveSavedTracks removes tracks from Liked Songs of the user, at most MaxLibraryIds
+// at once.
+func (c *Client) RemoveSavedTracks(ctx context.Context, ids []string) error {
+    return c.do(ctx, "DELETE", "/me/tracks", url.Values{"ids": {strings.Join(ids, ",")}}, nil, nil)
+}
+
+// SavedTracksContain tells for every track whether it is in Liked Songs of the user.
+func (c *Client) SavedTracksContain(ctx context.Context, ids []string) ([]bool, error) {
+    var saved []bool
+    if err := c.do(ctx, "GET", "/me/tracks/contains", url.Values{"ids": {strings.Join(ids, ",")}}, nil, &saved); err != nil {
+        return nil, err
+    }
+    return saved, nil
+}
+
+// SavedTracks returns a page of Liked Songs, the most recently added first.
+func (c *Client) SavedTracks(ctx context.Context, limit int, offset int) (*SavedTrackPage, error) {
+    var page SavedTrackPage
+    if err := c.do(ctx, "GET", "/me/tracks", pageQuery(limit, offset), nil, &page); err != nil {
+        return nil, err
+    }
+    return &page, nil
+}
@@ -0,0 +1,146 @@
+package spotify
+
+import (
+    "context"
+    "net/url"
+    "strconv"
+)
+
+// PlayOptions describes what to start playing, zero value resumes current playback.
+// Either ContextUri (album, playlist, artist, show) or Uris (tracks, episodes) is set.
+// Playback starts from the item at OffsetPosition or with OffsetUri, PositionMs into it.
+type PlayOptions struct {
+    DeviceId       string
+    ContextUri     string
+    Uris           []string
+    OffsetPosition int
+    OffsetUri      string
+    PositionMs     int
+}
+
+// PlaybackState returns current playback, or nil if nothing is playing on any device.
+func (c *Client) PlaybackState(ctx context.Context) (*PlaybackState, error) {
+    var state PlaybackState
+    query := url.Values{"additional_types": {"track,episode"}}
+    if err := c.do(ctx, "GET", "/me/player", query, nil, &state); err != nil {
+        return nil, err
+    }
+    if state.Device.Id == "" && state.Item == nil {
+        return nil, nil
+    }
+    return &state, nil
+}
+
+func (c *Client) Devices(ctx context.Context) ([]Device, error) {
+    var resBody struct {
+        Devices []Device `json:"devices"`
+    }
+    if err := c.do(ctx, "GET", "/me/player/devices", nil, nil, &resBody); err != nil {
+        return nil, err
+    }
+    return resBody.Devices, nil
+}
+
+func (c *Client) Play(ctx context.Context, opts PlayOptions) error {
+    body := map[string]interface{}{}
+    if opts.ContextUri != "" {
+        body["context_uri"] = opts.ContextUri
+    }
+    if len(opts.Uris) > 0 {
+        body["uris"] = opts.Uris
+    }
+    if opts.OffsetUri != "" {
+        body["offset"] = map[string]interface{}{"uri": opts.OffsetUri}
+    } else if opts.OffsetPosition > 0 {
+        body["offset"] = map[string]interface{}{"position": opts.OffsetPosition}
+    }
+    if opts.PositionMs > 0 {
+        body["position_ms"] = opts.PositionMs
+    }
+    // resuming is done with no body at all
+    var payload interface{}
+    if len(body) > 0 {
+        payload = body
+    }
+    return c.do(ctx, "PUT", "/me/player/play", deviceQuery(opts.DeviceId), payload, nil)
+}
+
+func (c *Client) Pause(ctx context.Context, deviceId string) error {
+    return c.do(ctx, "PUT", "/me/player/pause", deviceQuery(deviceId), nil, nil)
+}
+
+func (c *Client) Next(ctx context.Context, deviceId string) error {
+    return c.do(ctx, "POST", "/me/player/next", deviceQuery(deviceId), nil, nil)
+}
+
+// TransferPlayback moves playback to the device, play forces playback to start there
+// instead of keeping current state.
+func (c *Client) TransferPlayback(ctx context.Context, deviceId string, play bool) error {
+    body := map[string]interface{}{
+        "device_ids": []string{deviceId},
+        "play":       play,
+    }
+    return c.do(ctx, "PUT", "/me/player", nil, body, nil)
+}
+
+func (c *Client) Previous(ctx context.Context, deviceId string) error {
+    return c.do(ctx, "POST", "/me/player/previous", deviceQuery(deviceId), nil, nil)
+}
+
+// Seek moves playback to the position within current item.
+func (c *Client) Seek(ctx context.Context, deviceId string, positionMs int) error {
+    query := url.Values{"position_ms": {strconv.Itoa(positionMs)}}
+    if deviceId != "" {
+        query.Set("device_id", deviceId)
+    }
+    return c.do(ctx, "PUT", "/me/player/seek", query, nil, nil)
+}
+
+func (c *Client) SetVolume(ctx context.Context, deviceId string, percent int) error {
+    query := url.Values{"volume_percent": {strconv.Itoa(percent)}}
+    if deviceId != "" {
+        query.Set("device_id", deviceId)
+    }
+    return c.do(ctx, "PUT", "/me/player/volume", query, nil, nil)
+}
+
+func (c *Client) SetShuffle(ctx context.Context, deviceId string, shuffle bool) error {
+    query := url.Values{"state": {strconv.FormatBool(shuffle)}}
+    if deviceId != "" {
+        query.Set("device_id", deviceId)
+    }
+    return c.do(ctx, "PUT", "/me/player/shuffle", query, nil, nil)
+}
+
+// SetRepeat sets repeat mode, which is one of "off", "track" or "context".
+func (c *Client) SetRepeat(ctx context.Context, deviceId string, state string) error {
+    query := url.Values{"state": {state}}
+    if deviceId != "" {
+        query.Set("device_id", deviceId)
+    }
+    return c.do(ctx, "PUT", "/me/player/repeat", query, nil, nil)
+}
+
+// Queue is what is playing now and what comes next, both queued by user and coming
+// from the context.
+type Queue struct {
+    CurrentlyPlaying *Track  `json:"currently_playing"`
+    Queue            []Track `json:"queue"`
+}
+
+func (c *Client) Queue(ctx context.Context) (*Queue, error) {
+    var queue Queue
+    if err := c.do(ctx, "GET", "/me/player/queue", nil, nil, &queue); err != nil {
+        return nil, err
+    }
+    return &queue, nil
+}
+
+// AddToQueue adds track or episode to the end of user queue.
+func (c *Client) AddToQueue(ctx context.Context, deviceId string, uri string) error {
+    query := url.Values{"uri": {uri}}
+    if deviceId != "" {
+        query.Set("device_id", deviceId)
+    }
+    return c.do(ctx, "POST", "/me/player/queue", query, nil, nil)
+}
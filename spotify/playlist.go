@@ -0,0 +1,90 @@
+package spotify
+
+import (
+    "context"
+    "net/url"
+)
+
+// MaxPlaylistItems is how many items can be added or removed in a single request
+const MaxPlaylistItems = 100
+
+// PlaylistDetails are what can be set when creating or changing playlist, nil values
+// are left as they are. Collaborative playlists cannot be public.
+type PlaylistDetails struct {
+    Name          string  `json:"name,omitempty"`
+    Description   *string `json:"description,omitempty"`
+    Public        *bool   `json:"public,omitempty"`
+    Collaborative *bool   `json:"collaborative,omitempty"`
+}
+
+// PlaylistItemRef points at items to remove, all occurrences of Uri unless Positions
+// are given.
+type PlaylistItemRef struct {
+    Uri       string `json:"uri"`
+    Positions []int  `json:"positions,omitempty"`
+}
+
+// CurrentUserPlaylists returns a page of playlists the user owns or follows.
+func (c *Client) CurrentUserPlaylists(ctx context.Context, limit int, offset int) (*PlaylistPage, error) {
+    var page PlaylistPage
+    if err := c.do(ctx, "GET", "/me/playlists", pageQuery(limit, offset), nil, &page); err != nil {
+        return nil, err
+    }
+    return &page, nil
+}
+
+// PlaylistItems returns a page of playlist items, at most 100 of them.
+func (c *Client) PlaylistItems(ctx context.Context, id string, limit int, offset int) (*PlaylistItemPage, error) {
+    query := pageQuery(limit, offset)
+    query.Set("additional_types", "track,episode")
+    var page PlaylistItemPage
+    if err := c.do(ctx, "GET", "/playlists/"+url.PathEscape(id)+"/tracks", query, nil, &page); err != nil {
+        return nil, err
+    }
+    return &page, nil
+}
+
+func (c *Client) CreatePlaylist(ctx context.Context, userId string, details PlaylistDetails) (*Playlist, error) {
+    var playlist Playlist
+    if err := c.do(ctx, "POST", "/users/"+url.PathEscape(userId)+"/playlists", nil, details, &playlist); err != nil {
+        return nil, err
+    }
+    return &playlist, nil
+}
+
+func (c *Client) ChangePlaylistDetails(ctx context.Context, id string, details PlaylistDetails) error {
+    return c.do(ctx, "PUT", "/playlists/"+url.PathEscape(id), nil, details, nil)
+}
+
+// AddPlaylistItems inserts items at position, or appends them if position is negative,
+// and returns the new snapshot id. At most MaxPlaylistItems can be added at once.
+func (c *Client) AddPlaylistItems(ctx context.Context, id string, uris []string, position int) (string, error) {
+    body := map[string]interface{}{"uris": uris}
+    if position >= 0 {
+        body["position"] = position
+    }
+    var resBody struct {
+        SnapshotId string `json:"snapshot_id"`
+    }
+    if err := c.do(ctx, "POST", "/playlists/"+url.PathEscape(id)+"/tracks", nil, body, &resBody); err != nil {
+        return "", err
+    }
+    return resBody.SnapshotId, nil
+}
+
+// RemovePlaylistItems removes items and returns the new snapshot id. Positions are
+// taken as they were in snapshotId when it is given, otherwise as they are now. At most
+// MaxPlaylistItems can be removed at once.
+func (c *Client) RemovePlaylistItems(ctx context.Context, id string, items []PlaylistItemRef, snapshotId string) (string, error) {
+    body := map[string]interface{}{"tracks": items}
+    if snapshotId != "" {
+        body["snapshot_id"] = snapshotId
+    }
+    var resBody struct {
+        SnapshotId string `json:"snapshot_id"`
+    }
+    if err := c.do(ctx, "DELETE", "/playlists/"+url.PathEscape(id)+"/tracks", nil, body, &resBody); err != nil {
+        return "", err
+    }
+    return resBody.SnapshotId, nil
+}
@@ -0,0 +1,118 @@
+package spotify
+
+import (
+    "context"
+    "errors"
+    "math/rand"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// RetryPolicy decides how failed requests are repeated. Rate limited requests are
+// retried for any method as Spotify did not process them; transient server and network
+// errors are retried only for idempotent methods, as e.g. POST /me/player/next may have
+// already skipped the track when the response got lost.
+type RetryPolicy struct {
+    // MaxAttempts counts the first attempt too, values below 2 disable retries
+    MaxAttempts int
+    // BaseDelay is the backoff before the first retry, doubled on every next one
+    BaseDelay time.Duration
+    MaxDelay  time.Duration
+    // MaxRetryAfter caps how long we agree to wait when Spotify sends Retry-After,
+    // anything longer is returned to the caller as ErrRateLimited
+    MaxRetryAfter time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts:   4,
+    BaseDelay:     500 * time.Millisecond,
+    MaxDelay:      8 * time.Second,
+    MaxRetryAfter: 30 * time.Second,
+}
+
+var (
+    jitterMu sync.Mutex
+    jitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// backoff returns jittered exponential delay before the given retry (starting at 1),
+// randomly picked between half and full exponential value.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+    d := p.BaseDelay
+    for i := 1; i < retry && d < p.MaxDelay; i++ {
+        d *= 2
+    }
+    if p.MaxDelay > 0 && d > p.MaxDelay {
+        d = p.MaxDelay
+    }
+    if d <= 0 {
+        return 0
+    }
+    jitterMu.Lock()
+    defer jitterMu.Unlock()
+    return d/2 + time.Duration(jitter.Int63n(int64(d/2)+1))
+}
+
+// delay reports whether the failed attempt should be retried and after what pause.
+func (p RetryPolicy) delay(retry int, method string, err error) (time.Duration, bool) {
+    if retry >= p.MaxAttempts {
+        return 0, false
+    }
+    var apiErr *APIError
+    var tErr *transportError
+    switch {
+    case errors.As(err, &apiErr):
+        switch apiErr.StatusCode {
+        case http.StatusTooManyRequests:
+            if apiErr.RetryAfter == 0 {
+                return p.backoff(retry), true
+            }
+            return apiErr.RetryAfter, p.MaxRetryAfter == 0 || apiErr.RetryAfter <= p.MaxRetryAfter
+        case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+            return p.backoff(retry), isIdempotent(method)
+        }
+    case errors.As(err, &tErr):
+        return p.backoff(retry), isIdempotent(method)
+    }
+    return 0, false
+}
+
+func isIdempotent(method string) bool {
+    switch method {
+    case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+        return true
+    }
+    return false
+}
+
+// parseRetryAfter understands both forms of Retry-After header: seconds and HTTP date.
+func parseRetryAfter(value string) time.Duration {
+    if value == "" {
+        return 0
+    }
+    if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+        return time.Duration(seconds) * time.Second
+    }
+    if t, err := http.ParseTime(value); err == nil && time.Until(t) > 0 {
+        return time.Until(t)
+    }
+    return 0
+}
+
+// sleep waits for d unless context is done first or its deadline comes before d ends,
+// in which case there is no point in waiting at all.
+func sleep(ctx context.Context, d time.Duration) bool {
+    if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+        return false
+    }
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return false
+    case <-timer.C:
+        return true
+    }
+}
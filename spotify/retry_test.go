@@ -0,0 +1,143 @@
+package spotify
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// testPolicy keeps backoff short so tests that do retry finish quickly
+var testPolicy = RetryPolicy{
+    MaxAttempts:   4,
+    BaseDelay:     10 * time.Millisecond,
+    MaxDelay:      40 * time.Millisecond,
+    MaxRetryAfter: 2 * time.Second,
+}
+
+// newTestClient starts a server answering through respond, which gets the number of
+// the attempt starting at 1, and returns the client with a counter of attempts made.
+func newTestClient(t *testing.T, policy RetryPolicy, respond func(w http.ResponseWriter, attempt int)) (*Client, *int32) {
+    var attempts int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        respond(w, int(atomic.AddInt32(&attempts, 1)))
+    }))
+    t.Cleanup(server.Close)
+
+    client := NewClient(StaticToken("token"))
+    client.BaseUrl = server.URL
+    client.Retry = policy
+    return client, &attempts
+}
+
+// failTimes fails the first n attempts with given status and answers with a device list
+// afterwards
+func failTimes(n int, status int, retryAfter string) func(w http.ResponseWriter, attempt int) {
+    return func(w http.ResponseWriter, attempt int) {
+        if attempt <= n {
+            if retryAfter != "" {
+                w.Header().Set("Retry-After", retryAfter)
+            }
+            w.WriteHeader(status)
+            _, _ = w.Write([]byte(`{"error":{"status":` + strconv.Itoa(status) + `,"message":"failed"}}`))
+            return
+        }
+        _, _ = w.Write([]byte(`{"devices":[{"id":"d1","name":"Kitchen"}]}`))
+    }
+}
+
+func TestRetryAfterIsHonoured(t *testing.T) {
+    client, attempts := newTestClient(t, testPolicy, failTimes(1, http.StatusTooManyRequests, "1"))
+
+    start := time.Now()
+    devices, err := client.Devices(context.Background())
+    if err != nil {
+        t.Fatalf("Devices() failed: %v", err)
+    }
+    if len(devices) != 1 {
+        t.Errorf("got %d devices, want 1", len(devices))
+    }
+    if *attempts != 2 {
+        t.Errorf("got %d attempts, want 2", *attempts)
+    }
+    if elapsed := time.Since(start); elapsed < time.Second {
+        t.Errorf("retried after %v, Retry-After asked for 1s", elapsed)
+    }
+}
+
+func TestRetryAfterOverLimitIsNotWaitedFor(t *testing.T) {
+    client, attempts := newTestClient(t, testPolicy, failTimes(1, http.StatusTooManyRequests, "60"))
+
+    start := time.Now()
+    _, err := client.Devices(context.Background())
+    if !errors.Is(err, ErrRateLimited) {
+        t.Fatalf("got %v, want ErrRateLimited", err)
+    }
+    if *attempts != 1 {
+        t.Errorf("got %d attempts, want 1", *attempts)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("returned after %v, should not wait for Retry-After over MaxRetryAfter", elapsed)
+    }
+}
+
+func TestServiceUnavailableIsRetriedForGet(t *testing.T) {
+    client, attempts := newTestClient(t, testPolicy, failTimes(2, http.StatusServiceUnavailable, ""))
+
+    if _, err := client.Devices(context.Background()); err != nil {
+        t.Fatalf("Devices() failed: %v", err)
+    }
+    if *attempts != 3 {
+        t.Errorf("got %d attempts, want 3", *attempts)
+    }
+}
+
+func TestServiceUnavailableIsNotRetriedForNext(t *testing.T) {
+    client, attempts := newTestClient(t, testPolicy, failTimes(1, http.StatusServiceUnavailable, ""))
+
+    err := client.Next(context.Background(), "")
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+        t.Fatalf("got %v, want 503 APIError", err)
+    }
+    if *attempts != 1 {
+        t.Errorf("got %d attempts, POST /me/player/next must not be repeated", *attempts)
+    }
+}
+
+func TestDeadlineShorterThanBackoffStopsRetrying(t *testing.T) {
+    policy := testPolicy
+    policy.BaseDelay, policy.MaxDelay = time.Second, time.Second
+    client, attempts := newTestClient(t, policy, failTimes(10, http.StatusServiceUnavailable, ""))
+
+    ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    _, err := client.Devices(ctx)
+    if err == nil {
+        t.Fatal("Devices() succeeded, want error")
+    }
+    if *attempts != 1 {
+        t.Errorf("got %d attempts, want 1", *attempts)
+    }
+    if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+        t.Errorf("returned after %v, should give up without waiting for the deadline", elapsed)
+    }
+}
+
+func TestMaxAttemptsIsRespected(t *testing.T) {
+    client, attempts := newTestClient(t, testPolicy, failTimes(10, http.StatusServiceUnavailable, ""))
+
+    _, err := client.Devices(context.Background())
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+        t.Fatalf("got %v, want 503 APIError", err)
+    }
+    if *attempts != int32(testPolicy.MaxAttempts) {
+        t.Errorf("got %d attempts, want %d", *attempts, testPolicy.MaxAttempts)
+    }
+}
@@ -0,0 +1,23 @@
+package spotify
+
+import (
+    "context"
+    "strings"
+)
+
+// SearchTypes are the types Search accepts
+var SearchTypes = []string{"track", "album", "artist", "playlist", "show", "episode"}
+
+// Search looks for the query in the catalog. Query may contain field filters like
+// "artist:", "album:", "year:" or "isrc:", see Spotify docs for the whole syntax.
+func (c *Client) Search(ctx context.Context, query string, types []string, limit int, offset int) (*SearchResult, error) {
+    q := pageQuery(limit, offset)
+    q.Set("q", query)
+    q.Set("type", strings.Join(types, ","))
+
+    var result SearchResult
+    if err := c.do(ctx, "GET", "/search", q, nil, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
@@ -0,0 +1,183 @@
+package spotify
+
+// Device is a Spotify Connect device. Restricted devices do not accept Web API commands,
+// e.g. volume changes.
+type Device struct {
+    Id               string `json:"id"`
+    Name             string `json:"name"`
+    Type             string `json:"type"`
+    IsActive         bool   `json:"is_active"`
+    IsRestricted     bool   `json:"is_restricted"`
+    IsPrivateSession bool   `json:"is_private_session"`
+    VolumePercent    int    `json:"volume_percent"`
+}
+
+type User struct {
+    Id          string `json:"id"`
+    DisplayName string `json:"display_name"`
+}
+
+// Playlist changes its SnapshotId on every edit. Tracks is just the total when
+// playlists are listed, with the first page of items when a single one is requested.
+type Playlist struct {
+    Id            string            `json:"id"`
+    Uri           string            `json:"uri"`
+    Name          string            `json:"name"`
+    Description   string            `json:"description"`
+    Owner         User              `json:"owner"`
+    Public        bool              `json:"public"`
+    Collaborative bool              `json:"collaborative"`
+    SnapshotId    string            `json:"snapshot_id"`
+    Tracks        *PlaylistItemPage `json:"tracks,omitempty"`
+}
+
+// PlaylistItem is an entry of a playlist, Track is nil for items no longer available.
+type PlaylistItem struct {
+    AddedAt string `json:"added_at"`
+    AddedBy *User  `json:"added_by"`
+    Track   *Track `json:"track"`
+}
+
+type Artist struct {
+    Id   string `json:"id"`
+    Uri  string `json:"uri"`
+    Name string `json:"name"`
+}
+
+type Album struct {
+    Id          string   `json:"id"`
+    Uri         string   `json:"uri"`
+    Name        string   `json:"name"`
+    Artists     []Artist `json:"artists"`
+    ReleaseDate string   `json:"release_date"`
+}
+
+type Show struct {
+    Id        string `json:"id"`
+    Uri       string `json:"uri"`
+    Name      string `json:"name"`
+    Publisher string `json:"publisher"`
+}
+
+// Track is a track or, when Type is "episode", a podcast episode; Spotify returns both
+// wherever something playable is expected. Artists and Album are set for tracks only,
+// Show for episodes only.
+type Track struct {
+    Id         string   `json:"id"`
+    Uri        string   `json:"uri"`
+    Type       string   `json:"type"`
+    Name       string   `json:"name"`
+    DurationMs int      `json:"duration_ms"`
+    Artists    []Artist `json:"artists"`
+    Album      *Album   `json:"album"`
+    Show       *Show    `json:"show"`
+    // ExternalIds has ISRC of tracks, which identifies the recording across services
+    ExternalIds *ExternalIds `json:"external_ids,omitempty"`
+}
+
+type ExternalIds struct {
+    Isrc string `json:"isrc,omitempty"`
+}
+
+// PlaybackContext is what playback was started from: playlist, album, artist or show.
+type PlaybackContext struct {
+    Type string `json:"type"`
+    Uri  string `json:"uri"`
+}
+
+type PlaybackState struct {
+    Device       Device           `json:"device"`
+    RepeatState  string           `json:"repeat_state"`
+    ShuffleState bool             `json:"shuffle_state"`
+    Context      *PlaybackContext `json:"context"`
+    ProgressMs   int              `json:"progress_ms"`
+    IsPlaying    bool             `json:"is_playing"`
+    Item         *Track           `json:"item"`
+    // CurrentlyPlayingType is one of "track", "episode", "ad" or "unknown"
+    CurrentlyPlayingType string `json:"currently_playing_type"`
+}
+
+type PlaylistPage struct {
+    Items  []Playlist `json:"items"`
+    Total  int        `json:"total"`
+    Limit  int        `json:"limit"`
+    Offset int        `json:"offset"`
+    Next   string     `json:"next"`
+}
+
+type Category struct {
+    Id   string `json:"id"`
+    Name string `json:"name"`
+}
+
+type CategoryPage struct {
+    Items  []Category `json:"items"`
+    Total  int        `json:"total"`
+    Limit  int        `json:"limit"`
+    Offset int        `json:"offset"`
+    Next   string     `json:"next"`
+}
+
+type TrackPage struct {
+    Items  []Track `json:"items"`
+    Total  int     `json:"total"`
+    Limit  int     `json:"limit"`
+    Offset int     `json:"offset"`
+    Next   string  `json:"next"`
+}
+
+// SavedTrack is a track in user's library, AddedAt is RFC 3339 timestamp.
+type SavedTrack struct {
+    AddedAt string `json:"added_at"`
+    Track   Track  `json:"track"`
+}
+
+type SavedTrackPage struct {
+    Items  []SavedTrack `json:"items"`
+    Total  int          `json:"total"`
+    Limit  int          `json:"limit"`
+    Offset int          `json:"offset"`
+    Next   string       `json:"next"`
+}
+
+type PlaylistItemPage struct {
+    Items  []PlaylistItem `json:"items"`
+    Total  int            `json:"total"`
+    Limit  int            `json:"limit"`
+    Offset int            `json:"offset"`
+    Next   string         `json:"next"`
+}
+
+type AlbumPage struct {
+    Items  []Album `json:"items"`
+    Total  int     `json:"total"`
+    Limit  int     `json:"limit"`
+    Offset int     `json:"offset"`
+    Next   string  `json:"next"`
+}
+
+type ArtistPage struct {
+    Items  []Artist `json:"items"`
+    Total  int      `json:"total"`
+    Limit  int      `json:"limit"`
+    Offset int      `json:"offset"`
+    Next   string   `json:"next"`
+}
+
+type ShowPage struct {
+    Items  []Show `json:"items"`
+    Total  int    `json:"total"`
+    Limit  int    `json:"limit"`
+    Offset int    `json:"offset"`
+    Next   string `json:"next"`
+}
+
+// SearchResult has a page for every type searched for, others are nil.
+type SearchResult struct {
+    Tracks    *TrackPage    `json:"tracks"`
+    Albums    *AlbumPage    `json:"albums"`
+    Artists   *ArtistPage   `json:"artists"`
+    Playlists *PlaylistPage `json:"playlists"`
+    Shows     *ShowPage     `json:"shows"`
+    Episodes  *TrackPage    `json:"episodes"`
+}
@@ -0,0 +1,70 @@
+package spotify
+
+import (
+    "errors"
+    "net/url"
+    "strings"
+)
+
+// SplitUri splits Spotify URI like "spotify:playlist:37i9dQZF1DX" into its type and id.
+// User collections ("spotify:user:<id>:collection") are reported as "collection" type.
+func SplitUri(uri string) (kind string, id string) {
+    parts := strings.Split(uri, ":")
+    if len(parts) < 3 || parts[0] != "spotify" {
+        return "", ""
+    }
+    if parts[1] == "user" {
+        if parts[len(parts)-1] == "collection" {
+            return "collection", parts[2]
+        }
+        if len(parts) >= 5 && parts[3] == "playlist" {
+            return "playlist", parts[4]
+        }
+    }
+    return parts[1], parts[2]
+}
+
+var linkKinds = map[string]bool{
+    "track":    true,
+    "album":    true,
+    "artist":   true,
+    "playlist": true,
+    "show":     true,
+    "episode":  true,
+}
+
+var ErrNotUri = errors.New("not a Spotify URI or link")
+
+// ParseUri turns Spotify URI or open.spotify.com share link into canonical URI. Links
+// may have query parameters ("?si=..."), locale ("/intl-de/") and "/embed/" or
+// "/user/<id>/" prefixes. Anything else is ErrNotUri.
+func ParseUri(s string) (string, error) {
+    s = strings.TrimSpace(s)
+    if strings.HasPrefix(s, "spotify:") {
+        if kind, id := SplitUri(s); kind != "" && id != "" {
+            return s, nil
+        }
+        return "", ErrNotUri
+    }
+
+    if !strings.Contains(s, "://") {
+        s = "https://" + s
+    }
+    u, err := url.Parse(s)
+    if err != nil || u.Host != "open.spotify.com" {
+        return "", ErrNotUri
+    }
+    var parts []string
+    for _, p := range strings.Split(u.Path, "/") {
+        if p != "" && p != "embed" && !strings.HasPrefix(p, "intl-") {
+            parts = append(parts, p)
+        }
+    }
+    if len(parts) == 4 && parts[0] == "user" {
+        parts = parts[2:]
+    }
+    if len(parts) != 2 || !linkKinds[parts[0]] {
+        return "", ErrNotUri
+    }
+    return "spotify:" + parts[0] + ":" + parts[1], nil
+}
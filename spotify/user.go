@@ -0,0 +1,13 @@
+package spotify
+
+import (
+    "context"
+)
+
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+    var user User
+    if err := c.do(ctx, "GET", "/me", nil, nil, &user); err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+)
+
+const StateFileName = "state.json"
+
+// LocalState is what CLI remembers between runs besides credentials
+type LocalState struct {
+    // VolumeBeforeMute is the level to restore on unmute by device id, devices that are
+    // not muted have no entry
+    VolumeBeforeMute map[string]int `json:"volumes_before_mute,omitempty"`
+    // PlaylistSnapshots is the snapshot id by playlist id that `playlist show` printed
+    // last, item numbers user gives are from that snapshot
+    PlaylistSnapshots map[string]string `json:"playlist_snapshots,omitempty"`
+}
+
+// stateFilePath is the state of the profile, e.g. devices muted by another profile's
+// account are none of its business
+func stateFilePath(profile string) (string, error) {
+    dir, err := profileStateDir(profile)
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, StateFileName), nil
+}
+
+// updateLocalState runs fn on the stored state under the lock and stores the result,
+// missing state file is the same as an empty state
+func updateLocalState(ctx *Context, fn func(s *LocalState) error) error {
+    path, err := stateFilePath(ctx.Config.active)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return err
+    }
+    unlock, err := lockFile(path + ".lock")
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    var s LocalState
+    content, err := ioutil.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    if len(content) > 0 {
+        if jsonErr := json.Unmarshal(content, &s); jsonErr != nil {
+            return jsonErr
+        }
+    }
+    if s.VolumeBeforeMute == nil {
+        s.VolumeBeforeMute = map[string]int{}
+    }
+    if s.PlaylistSnapshots == nil {
+        s.PlaylistSnapshots = map[string]string{}
+    }
+    if err := fn(&s); err != nil {
+        return err
+    }
+    content, err = json.MarshalIndent(&s, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(path, content)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+// statusResult is playback state as status command outputs it
+type statusResult struct {
+    // State is one of "playing", "paused" or "stopped"
+    State      string          `json:"state"`
+    Type       string          `json:"type,omitempty"`
+    Item       *itemResult     `json:"item"`
+    ProgressMs int             `json:"progress_ms"`
+    Device     *spotify.Device `json:"device"`
+    Shuffle    bool            `json:"shuffle"`
+    Repeat     string          `json:"repeat,omitempty"`
+    Context    *contextResult  `json:"context"`
+}
+
+// itemResult is a track or an episode, Artists are publisher and Album is the show
+// for episodes
+type itemResult struct {
+    Type       string   `json:"type"`
+    Uri        string   `json:"uri"`
+    Name       string   `json:"name"`
+    Artists    []string `json:"artists"`
+    Album      string   `json:"album"`
+    DurationMs int      `json:"duration_ms"`
+}
+
+type contextResult struct {
+    Type string `json:"type"`
+    Uri  string `json:"uri"`
+    Name string `json:"name"`
+}
+
+// Track, Artists, Progress and Duration are shortcuts for --format templates, they
+// give empty values when nothing is playing instead of failing on nil item
+func (r *statusResult) Track() *itemResult {
+    if r.Item == nil {
+        return &itemResult{Artists: []string{}}
+    }
+    return r.Item
+}
+
+func (r *statusResult) Artists() []string {
+    return r.Track().Artists
+}
+
+func (r *statusResult) Progress() string {
+    return formatDuration(r.ProgressMs)
+}
+
+func (r *statusResult) Duration() string {
+    return r.Track().Duration()
+}
+
+// Duration is the length formatted as "m:ss"
+func (r *itemResult) Duration() string {
+    return formatDuration(r.DurationMs)
+}
+
+func newStatusResult(state *spotify.PlaybackState, contextName string) *statusResult {
+    if state == nil {
+        return &statusResult{State: "stopped"}
+    }
+    result := &statusResult{
+        State:      "paused",
+        Type:       state.CurrentlyPlayingType,
+        ProgressMs: state.ProgressMs,
+        Device:     &state.Device,
+        Shuffle:    state.ShuffleState,
+        Repeat:     state.RepeatState,
+    }
+    if state.IsPlaying {
+        result.State = "playing"
+    }
+    if state.Item != nil {
+        result.Item = newItemResult(state.Item)
+    }
+    if state.Context != nil {
+        result.Context = &contextResult{Type: state.Context.Type, Uri: state.Context.Uri, Name: contextName}
+    }
+    return result
+}
+
+func newItemResult(item *spotify.Track) *itemResult {
+    artists := artistNames(item.Artists)
+    album := ""
+    if item.Album != nil {
+        album = item.Album.Name
+    }
+    if item.Show != nil {
+        artists, album = []string{item.Show.Publisher}, item.Show.Name
+    }
+    if artists == nil {
+        artists = []string{}
+    }
+    return &itemResult{
+        Type:       item.Type,
+        Uri:        item.Uri,
+        Name:       item.Name,
+        Artists:    artists,
+        Album:      album,
+        DurationMs: item.DurationMs,
+    }
+}
+
+func statusCommand() *Command {
+    var porcelain, short bool
+    return &Command{
+        Name:    "status",
+        Aliases: []string{"now-playing", "np"},
+        Description: "Show what is playing right now\n" +
+            "Human format by default; --short prints a single line for status bars and\n" +
+            "--porcelain prints stable tab separated key/value lines for scripts. Both are ignored\n" +
+            "with --format or --output other than human.",
+        Flags: func(fs *flag.FlagSet) {
+            fs.BoolVar(&porcelain, "porcelain", false, "print machine readable key<TAB>value lines")
+            fs.BoolVar(&short, "short", false, "print a single line, e.g. for tmux status bar")
+        },
+        Run: func(ctx *Context, args []string) error {
+            state, err := ctx.Client.PlaybackState(ctx)
+            if err != nil {
+                return err
+            }
+            contextName := ""
+            if state != nil && state.Context != nil {
+                contextName = playbackContextName(ctx, state.Context.Uri)
+            }
+            return ctx.Render(newStatusResult(state, contextName), func(w io.Writer) {
+                switch {
+                case porcelain:
+                    printStatusPorcelain(w, state, contextName)
+                case short:
+                    printStatusShort(w, state)
+                default:
+                    printStatus(w, state, contextName)
+                }
+            })
+        },
+    }
+}
+
+// playbackContextName finds out name of the playlist, album, artist or show playback
+// was started from. It is a nice to have, so errors result in empty name.
+func playbackContextName(ctx *Context, uri string) string {
+    kind, id := spotify.SplitUri(uri)
+    switch kind {
+    case "playlist":
+        if p, err := ctx.Client.Playlist(ctx, id, "name"); err == nil {
+            return p.Name
+        }
+    case "album":
+        if a, err := ctx.Client.Album(ctx, id); err == nil {
+            return a.Name
+        }
+    case "artist":
+        if a, err := ctx.Client.Artist(ctx, id); err == nil {
+            return a.Name
+        }
+    case "show":
+        if s, err := ctx.Client.Show(ctx, id); err == nil {
+            return s.Name
+        }
+    case "collection":
+        return "Liked Songs"
+    }
+    return ""
+}
+
+// itemSubtitle is who made the item and where it is from: artists and album for
+// tracks, show and publisher for episodes
+func itemSubtitle(item *spotify.Track) (by string, from string) {
+    if item.Show != nil {
+        return item.Show.Publisher, item.Show.Name
+    }
+    if item.Album != nil {
+        from = item.Album.Name
+    }
+    return strings.Join(artistNames(item.Artists), ", "), from
+}
+
+func playingSymbol(state *spotify.PlaybackState) string {
+    if state.IsPlaying {
+        return "▶"
+    }
+    return "⏸"
+}
+
+func printStatus(w io.Writer, state *spotify.PlaybackState, contextName string) {
+    if state == nil {
+        _, _ = fmt.Fprintln(w, "Nothing is playing right now")
+        return
+    }
+    if state.Item == nil {
+        _, _ = fmt.Fprintln(w, playingSymbol(state)+" ("+state.CurrentlyPlayingType+")")
+    } else {
+        by, from := itemSubtitle(state.Item)
+        _, _ = fmt.Fprintln(w, playingSymbol(state)+" "+state.Item.Name)
+        _, _ = fmt.Fprintln(w, "  "+by+" — "+from)
+        _, _ = fmt.Fprintln(w, "  "+progressBar(state.ProgressMs, state.Item.DurationMs, 30)+" "+
+            formatDuration(state.ProgressMs)+" / "+formatDuration(state.Item.DurationMs))
+    }
+    _, _ = fmt.Fprintln(w, "  Device:  "+state.Device.Name+" ("+state.Device.Type+"), volume "+
+        strconv.Itoa(state.Device.VolumePercent)+"%")
+    _, _ = fmt.Fprintln(w, "  Shuffle: "+onOff(state.ShuffleState)+", repeat: "+state.RepeatState)
+    if state.Context != nil {
+        from := state.Context.Type
+        if contextName != "" {
+            from += " \"" + contextName + "\""
+        }
+        _, _ = fmt.Fprintln(w, "  Playing from "+from)
+    }
+}
+
+func printStatusShort(w io.Writer, state *spotify.PlaybackState) {
+    if state == nil || state.Item == nil {
+        _, _ = fmt.Fprintln(w, "■")
+        return
+    }
+    by, _ := itemSubtitle(state.Item)
+    _, _ = fmt.Fprintln(w, playingSymbol(state)+" "+by+" — "+state.Item.Name+
+        " ["+formatDuration(state.ProgressMs)+"/"+formatDuration(state.Item.DurationMs)+"]")
+}
+
+// printStatusPorcelain prints every field on its own line, the set of keys and their
+// order are stable so scripts can rely on them
+func printStatusPorcelain(w io.Writer, state *spotify.PlaybackState, contextName string) {
+    fields := [][2]string{{"state", "stopped"}}
+    if state != nil {
+        fields[0][1] = "paused"
+        if state.IsPlaying {
+            fields[0][1] = "playing"
+        }
+        var item spotify.Track
+        if state.Item != nil {
+            item = *state.Item
+        }
+        by, from := itemSubtitle(&item)
+        var contextType, contextUri string
+        if state.Context != nil {
+            contextType, contextUri = state.Context.Type, state.Context.Uri
+        }
+        fields = append(fields,
+            [2]string{"type", state.CurrentlyPlayingType},
+            [2]string{"uri", item.Uri},
+            [2]string{"name", item.Name},
+            [2]string{"artists", by},
+            [2]string{"album", from},
+            [2]string{"progress_ms", strconv.Itoa(state.ProgressMs)},
+            [2]string{"duration_ms", strconv.Itoa(item.DurationMs)},
+            [2]string{"device", state.Device.Name},
+            [2]string{"device_id", state.Device.Id},
+            [2]string{"volume", strconv.Itoa(state.Device.VolumePercent)},
+            [2]string{"shuffle", onOff(state.ShuffleState)},
+            [2]string{"repeat", state.RepeatState},
+            [2]string{"context_type", contextType},
+            [2]string{"context_uri", contextUri},
+            [2]string{"context_name", contextName},
+        )
+    }
+    for _, f := range fields {
+        _, _ = fmt.Fprintln(w, f[0]+"\t"+strings.NewReplacer("\t", " ", "\n", " ").Replace(f[1]))
+    }
+}
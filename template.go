@@ -0,0 +1,74 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "reflect"
+    "strings"
+    "text/template"
+    "unicode/utf8"
+)
+
+// templateFuncs are helpers available in --format templates besides the builtin ones
+var templateFuncs = template.FuncMap{
+    "join": func(items []string, sep string) string {
+        return strings.Join(items, sep)
+    },
+    "duration": formatDuration,
+    "truncate": truncate,
+    "pad": func(width int, v interface{}) string {
+        s := fmt.Sprint(v)
+        if n := utf8.RuneCountInString(s); n < width {
+            return s + strings.Repeat(" ", width-n)
+        }
+        return s
+    },
+    "padLeft": func(width int, v interface{}) string {
+        s := fmt.Sprint(v)
+        if n := utf8.RuneCountInString(s); n < width {
+            return strings.Repeat(" ", width-n) + s
+        }
+        return s
+    },
+    "upper": strings.ToUpper,
+    "lower": strings.ToLower,
+}
+
+// parseFormat parses --format template, see templateFuncs for what it can call
+func parseFormat(format string) (*template.Template, error) {
+    t, err := template.New("format").Funcs(templateFuncs).Option("missingkey=zero").Parse(format)
+    if err != nil {
+        return nil, errors.New("malformed --format: " + strings.TrimPrefix(err.Error(), "template: "))
+    }
+    return t, nil
+}
+
+// executeFormat writes a line for every item of a list, or a single line for anything
+// else
+func executeFormat(t *template.Template, value interface{}) ([]byte, error) {
+    items := []interface{}{value}
+    if v := reflect.ValueOf(value); v.Kind() == reflect.Slice {
+        items = items[:0]
+        for i := 0; i < v.Len(); i++ {
+            items = append(items, v.Index(i).Interface())
+        }
+    }
+    var out bytes.Buffer
+    for _, item := range items {
+        if err := t.Execute(&out, item); err != nil {
+            return nil, errors.New("cannot apply --format: " + strings.TrimPrefix(err.Error(), "template: "))
+        }
+        out.WriteByte('\n')
+    }
+    return out.Bytes(), nil
+}
+
+// truncate cuts value to at most width characters, marking the cut with an ellipsis
+func truncate(width int, v interface{}) string {
+    s := fmt.Sprint(v)
+    if width < 1 || utf8.RuneCountInString(s) <= width {
+        return s
+    }
+    return string([]rune(s)[:width-1]) + "…"
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "spotify/spotify"
+)
+
+type volumeResult struct {
+    Device        string `json:"device"`
+    DeviceId      string `json:"device_id"`
+    VolumePercent int    `json:"volume_percent"`
+}
+
+// renderVolume renders volume level of the device, message is the human output
+func renderVolume(ctx *Context, device *spotify.Device, level int, message string) error {
+    result := volumeResult{Device: device.Name, DeviceId: device.Id, VolumePercent: level}
+    return ctx.Render(result, func(w io.Writer) {
+        _, _ = fmt.Fprintln(w, message)
+    })
+}
+
+func volumeCommand() *Command {
+    return &Command{
+        Name: "volume",
+        Description: "Show or change volume of the active device\n" +
+            "Level is absolute (40) or relative to the current one (+10, -5), always within 0-100.",
+        Args: []Arg{
+            {Name: "level", Description: "new volume, e.g. 40, +10 or -5", Optional: true},
+        },
+        Run: func(ctx *Context, args []string) error {
+            device, err := targetDevice(ctx)
+            if err != nil {
+                return err
+            }
+            if len(args) == 0 {
+                return renderVolume(ctx, device, device.VolumePercent, "Volume is "+strconv.Itoa(device.VolumePercent)+"% on "+device.Name)
+            }
+
+            level, err := strconv.Atoi(args[0])
+            if err != nil {
+                return usageErrorf(ctx.Command, "malformed level %q, use e.g. 40, +10 or -5", args[0])
+            }
+            if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+                level += device.VolumePercent
+            }
+            return setVolume(ctx, device, level)
+        },
+    }
+}
+
+func muteCommand() *Command {
+    return &Command{
+        Name:        "mute",
+        Description: "Mute the active device, remembering the volume for unmute",
+        Run: func(ctx *Context, args []string) error {
+            device, err := targetDevice(ctx)
+            if err != nil {
+                return err
+            }
+            if device.VolumePercent == 0 {
+                return renderVolume(ctx, device, 0, "Already muted")
+            }
+            if err := ctx.Client.SetVolume(ctx, device.Id, 0); err != nil {
+                return err
+            }
+            // remembered only once muted, a failed mute must not make unmute restore
+            // the level over whatever the device is changed to in the meantime
+            err = updateLocalState(ctx, func(s *LocalState) error {
+                s.VolumeBeforeMute[device.Id] = device.VolumePercent
+                return nil
+            })
+            if err != nil {
+                return err
+            }
+
+            return renderVolume(ctx, device, 0, "Muted "+device.Name)
+        },
+    }
+}
+
+func unmuteCommand() *Command {
+    return &Command{
+        Name:        "unmute",
+        Description: "Restore the volume the device had before mute",
+        Run: func(ctx *Context, args []string) error {
+            device, err := targetDevice(ctx)
+            if err != nil {
+                return err
+            }
+            level := 0
+            err = updateLocalState(ctx, func(s *LocalState) error {
+                level = s.VolumeBeforeMute[device.Id]
+                return nil
+            })
+            if err != nil {
+                return err
+            }
+            if level == 0 {
+                if device.VolumePercent > 0 {
+                    return renderVolume(ctx, device, device.VolumePercent, "Not muted")
+                }
+                return errors.New("volume before mute is unknown, set it with `spotify volume <level>`")
+            }
+            if err := setVolume(ctx, device, level); err != nil {
+                return err
+            }
+            return updateLocalState(ctx, func(s *LocalState) error {
+                delete(s.VolumeBeforeMute, device.Id)
+                return nil
+            })
+        },
+    }
+}
+
+func setVolume(ctx *Context, device *spotify.Device, level int) error {
+    if level < 0 {
+        level = 0
+    }
+    if level > 100 {
+        level = 100
+    }
+    if err := ctx.Client.SetVolume(ctx, device.Id, level); err != nil {
+        return err
+    }
+
+    return renderVolume(ctx, device, level, "Volume set to "+strconv.Itoa(level)+"% on "+device.Name)
+}
+
+// targetDevice is the active device or, if there is none, the one fallbackDevice picks.
+// Restricted devices are refused as they do not accept commands anyway.
+func targetDevice(ctx *Context) (*spotify.Device, error) {
+    devices, err := ctx.Client.Devices(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if len(devices) == 0 {
+        return nil, ErrNoDevices
+    }
+    device := preferredDevice(ctx, devices)
+    for i := range devices {
+        if devices[i].IsActive {
+            device = &devices[i]
+            break
+        }
+    }
+    if device.IsRestricted {
+        return nil, errors.New("device " + device.Name + " does not accept commands from Spotify Web API")
+    }
+    return device, nil
+}